@@ -0,0 +1,147 @@
+package application
+
+import (
+	"container/list"
+	"maps"
+	"sync"
+	"time"
+)
+
+type infoCacheEntry struct {
+	key     string
+	info    ObjectInfo
+	expires time.Time
+}
+
+// infoFetch counts the lookups of one key in flight and the invalidations seen since
+// the first of them started.
+type infoFetch struct {
+	pending    int
+	generation uint64
+}
+
+// infoCache is a small LRU cache of object metadata with a time-to-live.
+type infoCache struct {
+	mutex   sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+	// fetches only holds keys being looked up, so it stays as small as the number
+	// of concurrent lookups.
+	fetches map[string]*infoFetch
+}
+
+func newInfoCache(size int, ttl time.Duration) *infoCache {
+	return &infoCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+		fetches: map[string]*infoFetch{},
+	}
+}
+
+func infoCacheKey(bucketName string, objectKey string) string {
+	return bucketName + "\x00" + objectKey
+}
+
+func (cache *infoCache) get(key string) (ObjectInfo, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, found := cache.entries[key]
+	if !found {
+		return ObjectInfo{}, false
+	}
+	entry := element.Value.(*infoCacheEntry)
+	if cache.ttl > 0 && time.Now().After(entry.expires) {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+		return ObjectInfo{}, false
+	}
+	cache.order.MoveToFront(element)
+	info := entry.info
+	info.Metadata = maps.Clone(info.Metadata)
+	return info, true
+}
+
+// begin registers a lookup of key that is about to start and returns the generation
+// to hand to finish.
+func (cache *infoCache) begin(key string) uint64 {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	fetch, found := cache.fetches[key]
+	if !found {
+		fetch = &infoFetch{}
+		cache.fetches[key] = fetch
+	}
+	fetch.pending++
+	return fetch.generation
+}
+
+// finish ends a lookup started with begin and caches its result, unless the key was
+// invalidated while the lookup was in flight: the result may then predate the write
+// that invalidated it. A nil info (a failed lookup) caches nothing.
+func (cache *infoCache) finish(key string, generation uint64, info *ObjectInfo) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	fetch := cache.fetches[key]
+	fetch.pending--
+	if fetch.pending == 0 {
+		delete(cache.fetches, key)
+	}
+	if info != nil && fetch.generation == generation {
+		cache.put(key, *info)
+	}
+}
+
+// put stores info under key. The caller must hold the mutex.
+func (cache *infoCache) put(key string, info ObjectInfo) {
+	info.Metadata = maps.Clone(info.Metadata)
+	entry := &infoCacheEntry{key: key, info: info, expires: time.Now().Add(cache.ttl)}
+	if element, found := cache.entries[key]; found {
+		element.Value = entry
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(entry)
+	if cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*infoCacheEntry).key)
+	}
+}
+
+func (cache *infoCache) remove(key string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if fetch, found := cache.fetches[key]; found {
+		fetch.generation++
+	}
+	if element, found := cache.entries[key]; found {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+	}
+}
+
+// cache returns the metadata cache of the service, or nil when caching is disabled.
+func (service *s3Service) cache() *infoCache {
+	if service.InfoCacheSize <= 0 {
+		return nil
+	}
+	service.infoCacheOnce.Do(func() {
+		service.infoCache = newInfoCache(service.InfoCacheSize, service.InfoCacheTTL)
+	})
+	return service.infoCache
+}
+
+// invalidateInfo drops the cached metadata of an object after it was written or deleted.
+func (service *s3Service) invalidateInfo(bucketName string, objectKey string) {
+	if cache := service.cache(); cache != nil {
+		cache.remove(infoCacheKey(bucketName, objectKey))
+	}
+}
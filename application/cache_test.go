@@ -0,0 +1,37 @@
+package application
+
+import (
+	"testing"
+)
+
+func TestInfoCacheSkipsLookupsInvalidatedInFlight(t *testing.T) {
+	cache := newInfoCache(10, 0)
+	generation := cache.begin("k")
+	cache.remove("k")
+	cache.finish("k", generation, &ObjectInfo{ETag: "old"})
+	if _, found := cache.get("k"); found {
+		t.Fatal("a lookup that raced with an invalidation was cached")
+	}
+	if len(cache.fetches) != 0 {
+		t.Errorf("fetches = %v, want none left", cache.fetches)
+	}
+
+	generation = cache.begin("k")
+	cache.finish("k", generation, &ObjectInfo{ETag: "new"})
+	if info, found := cache.get("k"); !found || info.ETag != "new" {
+		t.Errorf("get = %+v, %v, want the new entry", info, found)
+	}
+}
+
+func TestInfoCacheCopiesMetadata(t *testing.T) {
+	cache := newInfoCache(10, 0)
+	metadata := map[string]string{"a": "1"}
+	cache.finish("k", cache.begin("k"), &ObjectInfo{Metadata: metadata})
+	metadata["a"] = "changed by the caller of put"
+
+	info, _ := cache.get("k")
+	info.Metadata["a"] = "changed by the caller of get"
+	if info, _ := cache.get("k"); info.Metadata["a"] != "1" {
+		t.Errorf("cached metadata = %v, want it unaffected by callers", info.Metadata)
+	}
+}
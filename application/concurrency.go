@@ -0,0 +1,97 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// defaultConcurrency is used by batch operations when the caller asks for none,
+// matching the default of the SDK transfer managers.
+const defaultConcurrency = 5
+
+type slotKey struct{}
+
+// clampConcurrency turns a requested concurrency into the one actually used: the
+// default when none is requested, never more than MaxConcurrency, and a single
+// worker for work running inside a batch that already holds a slot.
+func (service *s3Service) clampConcurrency(ctx context.Context, concurrency int) int {
+	if ctx.Value(slotKey{}) != nil {
+		return 1
+	}
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	if service.MaxConcurrency > 0 && int64(concurrency) > service.MaxConcurrency {
+		concurrency = int(service.MaxConcurrency)
+	}
+	return concurrency
+}
+
+// acquire takes weight slots of the service-wide semaphore, blocking until they are
+// available, and returns the function that gives them back. Nothing is taken when
+// MaxConcurrency is unset or the context already holds a slot.
+func (service *s3Service) acquire(ctx context.Context, weight int) (func(), error) {
+	if service.MaxConcurrency <= 0 || ctx.Value(slotKey{}) != nil {
+		return func() {}, nil
+	}
+	service.limiterOnce.Do(func() {
+		service.limiter = semaphore.NewWeighted(service.MaxConcurrency)
+	})
+	if int64(weight) > service.MaxConcurrency {
+		weight = int(service.MaxConcurrency)
+	}
+	if err := service.limiter.Acquire(ctx, int64(weight)); err != nil {
+		return nil, err
+	}
+	return func() { service.limiter.Release(int64(weight)) }, nil
+}
+
+// forEach calls fn for every index in [0, count) from at most concurrency goroutines.
+// Each call holds one slot of the service-wide semaphore while it runs. Errors of
+// the individual calls are joined together; work not yet started is skipped once
+// ctx is done.
+func (service *s3Service) forEach(ctx context.Context, concurrency int, count int,
+	fn func(ctx context.Context, i int) error) error {
+	concurrency = service.clampConcurrency(ctx, concurrency)
+
+	var mutex sync.Mutex
+	var errs []error
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				err := service.runSlot(ctx, func(ctx context.Context) error { return fn(ctx, i) })
+				if err != nil {
+					mutex.Lock()
+					errs = append(errs, err)
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < count && ctx.Err() == nil; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (service *s3Service) runSlot(ctx context.Context, fn func(ctx context.Context) error) error {
+	release, err := service.acquire(ctx, 1)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(context.WithValue(ctx, slotKey{}, true))
+}
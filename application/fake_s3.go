@@ -0,0 +1,775 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// FakeS3 is an in-memory implementation of the S3 API used by the service, for tests
+// that shouldn't depend on a real endpoint or LocalStack. It keeps buckets, objects
+// and multipart uploads in maps and mimics the S3 behavior the service relies on:
+// prefixes, delimiters, continuation tokens, ranged reads, the If-Match, If-None-Match
+// and x-amz-copy-source-if-match preconditions and the NotFound/NoSuchKey errors.
+// Versioning, ACLs and encryption are accepted but not modelled.
+type FakeS3 struct {
+	mutex    sync.Mutex
+	buckets  map[string]*fakeBucket
+	uploads  map[string]*fakeUpload
+	uploadID int
+}
+
+type fakeBucket struct {
+	region  string
+	objects map[string]*fakeObject
+	tags    []types.Tag
+	logging *types.LoggingEnabled
+	tiering map[string]types.IntelligentTieringConfiguration
+	policy  *string
+}
+
+type fakeObject struct {
+	body         []byte
+	etag         string
+	lastModified time.Time
+	headers      fakeHeaders
+	// checksumSHA256 is only kept for objects uploaded with a SHA-256 checksum. For
+	// multipart uploads it is the checksum of the part checksums, as in S3.
+	checksumSHA256 *string
+	// partsCount is the number of parts of objects created by a multipart upload.
+	partsCount int
+}
+
+// fakeHeaders are the object headers stored alongside the body.
+type fakeHeaders struct {
+	contentType        *string
+	cacheControl       *string
+	contentLanguage    *string
+	contentEncoding    *string
+	contentDisposition *string
+	expires            *time.Time
+	metadata           map[string]string
+	storageClass       types.StorageClass
+}
+
+type fakeUpload struct {
+	bucket   string
+	key      string
+	headers  fakeHeaders
+	parts    map[int32][]byte
+	checksum types.ChecksumAlgorithm
+}
+
+// NewFakeS3 returns an empty in-memory S3. Pass it to NewS3ServiceWithClient.
+func NewFakeS3() *FakeS3 {
+	return &FakeS3{
+		buckets: map[string]*fakeBucket{},
+		uploads: map[string]*fakeUpload{},
+	}
+}
+
+func fakeError(code string, message string) error {
+	return &smithy.GenericAPIError{Code: code, Message: message}
+}
+
+func fakeETag(body []byte) string {
+	sum := md5.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// etagMatches evaluates an If-Match style condition, which may be quoted or "*".
+func etagMatches(condition string, etag string) bool {
+	return condition == "*" || strings.Trim(condition, `"`) == strings.Trim(etag, `"`)
+}
+
+func preconditionFailed() error {
+	return fakeError("PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+}
+
+// bucket returns the named bucket. The caller must hold the mutex.
+func (fake *FakeS3) bucket(name *string) (*fakeBucket, error) {
+	bucket, found := fake.buckets[aws.ToString(name)]
+	if !found {
+		return nil, &types.NoSuchBucket{Message: aws.String("The specified bucket does not exist")}
+	}
+	return bucket, nil
+}
+
+// object returns the named object. The caller must hold the mutex.
+func (fake *FakeS3) object(bucketName *string, key *string) (*fakeObject, error) {
+	bucket, err := fake.bucket(bucketName)
+	if err != nil {
+		return nil, err
+	}
+	object, found := bucket.objects[aws.ToString(key)]
+	if !found {
+		return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+	}
+	return object, nil
+}
+
+func (fake *FakeS3) ListBuckets(ctx context.Context, params *s3.ListBucketsInput, optFns ...func(*s3.Options)) (*s3.ListBucketsOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	var names []string
+	for name := range fake.buckets {
+		if name > aws.ToString(params.ContinuationToken) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	output := &s3.ListBucketsOutput{}
+	if limit := int(aws.ToInt32(params.MaxBuckets)); limit > 0 && len(names) > limit {
+		names = names[:limit]
+		output.ContinuationToken = aws.String(names[limit-1])
+	}
+	for _, name := range names {
+		output.Buckets = append(output.Buckets, types.Bucket{Name: aws.String(name)})
+	}
+	return output, nil
+}
+
+func (fake *FakeS3) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	name := aws.ToString(params.Bucket)
+	if _, found := fake.buckets[name]; found {
+		return nil, &types.BucketAlreadyOwnedByYou{Message: aws.String("Your previous request to create the named bucket succeeded and you already own it.")}
+	}
+	bucket := &fakeBucket{objects: map[string]*fakeObject{}}
+	if params.CreateBucketConfiguration != nil {
+		bucket.region = string(params.CreateBucketConfiguration.LocationConstraint)
+	}
+	fake.buckets[name] = bucket
+	return &s3.CreateBucketOutput{Location: aws.String("/" + name)}, nil
+}
+
+func (fake *FakeS3) DeleteBucket(ctx context.Context, params *s3.DeleteBucketInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(bucket.objects) > 0 {
+		return nil, fakeError("BucketNotEmpty", "The bucket you tried to delete is not empty")
+	}
+	delete(fake.buckets, aws.ToString(params.Bucket))
+	return &s3.DeleteBucketOutput{}, nil
+}
+
+func (fake *FakeS3) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, found := fake.buckets[aws.ToString(params.Bucket)]
+	if !found {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadBucketOutput{BucketRegion: aws.String(bucket.region)}, nil
+}
+
+func (fake *FakeS3) GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetBucketLocationOutput{LocationConstraint: types.BucketLocationConstraint(bucket.region)}, nil
+}
+
+func (fake *FakeS3) GetBucketTagging(ctx context.Context, params *s3.GetBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketTaggingOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if len(bucket.tags) == 0 {
+		return nil, fakeError("NoSuchTagSet", "The TagSet does not exist")
+	}
+	return &s3.GetBucketTaggingOutput{TagSet: append([]types.Tag(nil), bucket.tags...)}, nil
+}
+
+func (fake *FakeS3) PutBucketTagging(ctx context.Context, params *s3.PutBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketTaggingOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	bucket.tags = nil
+	if params.Tagging != nil {
+		bucket.tags = append(bucket.tags, params.Tagging.TagSet...)
+	}
+	return &s3.PutBucketTaggingOutput{}, nil
+}
+
+func (fake *FakeS3) DeleteBucketTagging(ctx context.Context, params *s3.DeleteBucketTaggingInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketTaggingOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	bucket.tags = nil
+	return &s3.DeleteBucketTaggingOutput{}, nil
+}
+
+func (fake *FakeS3) GetBucketLogging(ctx context.Context, params *s3.GetBucketLoggingInput, optFns ...func(*s3.Options)) (*s3.GetBucketLoggingOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetBucketLoggingOutput{LoggingEnabled: bucket.logging}, nil
+}
+
+func (fake *FakeS3) PutBucketLogging(ctx context.Context, params *s3.PutBucketLoggingInput, optFns ...func(*s3.Options)) (*s3.PutBucketLoggingOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	bucket.logging = nil
+	if params.BucketLoggingStatus != nil {
+		bucket.logging = params.BucketLoggingStatus.LoggingEnabled
+	}
+	return &s3.PutBucketLoggingOutput{}, nil
+}
+
+func (fake *FakeS3) GetBucketPolicy(ctx context.Context, params *s3.GetBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.GetBucketPolicyOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if bucket.policy == nil {
+		return nil, fakeError("NoSuchBucketPolicy", "The bucket policy does not exist")
+	}
+	return &s3.GetBucketPolicyOutput{Policy: bucket.policy}, nil
+}
+
+func (fake *FakeS3) PutBucketPolicy(ctx context.Context, params *s3.PutBucketPolicyInput, optFns ...func(*s3.Options)) (*s3.PutBucketPolicyOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	bucket.policy = params.Policy
+	return &s3.PutBucketPolicyOutput{}, nil
+}
+
+func (fake *FakeS3) GetBucketIntelligentTieringConfiguration(ctx context.Context, params *s3.GetBucketIntelligentTieringConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketIntelligentTieringConfigurationOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	configuration, found := bucket.tiering[aws.ToString(params.Id)]
+	if !found {
+		return nil, fakeError("NoSuchConfiguration", "The specified configuration does not exist.")
+	}
+	return &s3.GetBucketIntelligentTieringConfigurationOutput{IntelligentTieringConfiguration: &configuration}, nil
+}
+
+func (fake *FakeS3) PutBucketIntelligentTieringConfiguration(ctx context.Context, params *s3.PutBucketIntelligentTieringConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketIntelligentTieringConfigurationOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if bucket.tiering == nil {
+		bucket.tiering = map[string]types.IntelligentTieringConfiguration{}
+	}
+	if params.IntelligentTieringConfiguration != nil {
+		bucket.tiering[aws.ToString(params.Id)] = *params.IntelligentTieringConfiguration
+	}
+	return &s3.PutBucketIntelligentTieringConfigurationOutput{}, nil
+}
+
+func (fake *FakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	prefix, delimiter := aws.ToString(params.Prefix), aws.ToString(params.Delimiter)
+	after := aws.ToString(params.StartAfter)
+	if params.ContinuationToken != nil {
+		after = aws.ToString(params.ContinuationToken)
+	}
+	maxKeys := int(aws.ToInt32(params.MaxKeys))
+	if params.MaxKeys == nil {
+		maxKeys = 1000
+	}
+
+	var keys []string
+	for key := range bucket.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	output := &s3.ListObjectsV2Output{
+		Name:              params.Bucket,
+		Prefix:            params.Prefix,
+		Delimiter:         params.Delimiter,
+		MaxKeys:           aws.Int32(int32(maxKeys)),
+		ContinuationToken: params.ContinuationToken,
+		StartAfter:        params.StartAfter,
+		IsTruncated:       aws.Bool(false),
+	}
+	count := 0
+	lastPrefix := ""
+	for _, key := range keys {
+		// Keys rolled up into a common prefix are skipped as a whole, like S3 does.
+		entry, rolledUp := key, false
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				entry, rolledUp = key[:len(prefix)+i+len(delimiter)], true
+			}
+		}
+		if entry <= after || (rolledUp && entry == lastPrefix) {
+			continue
+		}
+		if count == maxKeys {
+			// Like S3, MaxKeys=0 returns an empty page that isn't truncated, since
+			// there is no key to continue after.
+			if maxKeys > 0 {
+				output.IsTruncated = aws.Bool(true)
+				output.NextContinuationToken = aws.String(after)
+			}
+			break
+		}
+		if rolledUp {
+			output.CommonPrefixes = append(output.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(entry)})
+			lastPrefix = entry
+			// Continue after every key sharing this common prefix.
+			after = entry + string(rune(0x10FFFF))
+		} else {
+			object := bucket.objects[key]
+			output.Contents = append(output.Contents, types.Object{
+				Key:          aws.String(key),
+				Size:         aws.Int64(int64(len(object.body))),
+				ETag:         aws.String(object.etag),
+				LastModified: aws.Time(object.lastModified),
+				StorageClass: types.ObjectStorageClass(object.headers.storageClass),
+			})
+			after = key
+		}
+		count++
+	}
+	output.KeyCount = aws.Int32(int32(count))
+	return output, nil
+}
+
+func (fake *FakeS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	object, err := fake.object(params.Bucket, params.Key)
+	if err != nil {
+		// HEAD responses have no body, so S3 can only answer with a bare 404.
+		return nil, &types.NotFound{}
+	}
+	headers := object.headers
+	return &s3.HeadObjectOutput{
+		AcceptRanges:       aws.String("bytes"),
+		ContentLength:      aws.Int64(int64(len(object.body))),
+		ETag:               aws.String(object.etag),
+		LastModified:       aws.Time(object.lastModified),
+		ContentType:        headers.contentType,
+		CacheControl:       headers.cacheControl,
+		ContentLanguage:    headers.contentLanguage,
+		ContentEncoding:    headers.contentEncoding,
+		ContentDisposition: headers.contentDisposition,
+		Expires:            headers.expires,
+		Metadata:           copyMetadata(headers.metadata),
+		StorageClass:       headers.storageClass,
+	}, nil
+}
+
+func (fake *FakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	object, err := fake.object(params.Bucket, params.Key)
+	if err != nil {
+		return nil, err
+	}
+	if params.IfMatch != nil && !etagMatches(aws.ToString(params.IfMatch), object.etag) {
+		return nil, preconditionFailed()
+	}
+	size := int64(len(object.body))
+	body := object.body
+	output := &s3.GetObjectOutput{
+		AcceptRanges:       aws.String("bytes"),
+		ETag:               aws.String(object.etag),
+		LastModified:       aws.Time(object.lastModified),
+		ContentType:        object.headers.contentType,
+		CacheControl:       object.headers.cacheControl,
+		ContentLanguage:    object.headers.contentLanguage,
+		ContentEncoding:    object.headers.contentEncoding,
+		ContentDisposition: object.headers.contentDisposition,
+		Expires:            object.headers.expires,
+		Metadata:           copyMetadata(object.headers.metadata),
+		StorageClass:       object.headers.storageClass,
+	}
+	if params.Range != nil {
+		start, end, err := parseFakeRange(aws.ToString(params.Range), size)
+		if err != nil {
+			return nil, err
+		}
+		body = object.body[start : end+1]
+		output.ContentRange = aws.String(fmt.Sprintf("bytes %v-%v/%v", start, end, size))
+	}
+	output.ContentLength = aws.Int64(int64(len(body)))
+	output.Body = io.NopCloser(bytes.NewReader(append([]byte(nil), body...)))
+	return output, nil
+}
+
+func (fake *FakeS3) GetObjectAttributes(ctx context.Context, params *s3.GetObjectAttributesInput, optFns ...func(*s3.Options)) (*s3.GetObjectAttributesOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	object, err := fake.object(params.Bucket, params.Key)
+	if err != nil {
+		return nil, err
+	}
+	output := &s3.GetObjectAttributesOutput{LastModified: aws.Time(object.lastModified)}
+	for _, attribute := range params.ObjectAttributes {
+		switch attribute {
+		case types.ObjectAttributesEtag:
+			output.ETag = aws.String(strings.Trim(object.etag, `"`))
+		case types.ObjectAttributesObjectSize:
+			output.ObjectSize = aws.Int64(int64(len(object.body)))
+		case types.ObjectAttributesStorageClass:
+			output.StorageClass = object.headers.storageClass
+		case types.ObjectAttributesChecksum:
+			if object.checksumSHA256 != nil {
+				output.Checksum = &types.Checksum{ChecksumSHA256: object.checksumSHA256}
+			}
+		case types.ObjectAttributesObjectParts:
+			if object.partsCount > 0 {
+				output.ObjectParts = &types.GetObjectAttributesParts{TotalPartsCount: aws.Int32(int32(object.partsCount))}
+			}
+		}
+	}
+	return output, nil
+}
+
+// parseFakeRange parses a "bytes=start-end" or "bytes=start-" range header into
+// inclusive offsets.
+func parseFakeRange(value string, size int64) (int64, int64, error) {
+	spec, found := strings.CutPrefix(value, "bytes=")
+	first, last, dash := strings.Cut(spec, "-")
+	start, err := strconv.ParseInt(first, 10, 64)
+	if !found || !dash || err != nil || start >= size {
+		return 0, 0, fakeError("InvalidRange", "The requested range is not satisfiable")
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, fakeError("InvalidRange", "The requested range is not satisfiable")
+		}
+		end = min(end, size-1)
+	}
+	return start, end, nil
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		copied[strings.ToLower(key)] = value
+	}
+	return copied
+}
+
+func (fake *FakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	var body []byte
+	if params.Body != nil {
+		var err error
+		if body, err = io.ReadAll(params.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if existing, found := bucket.objects[aws.ToString(params.Key)]; found {
+		if params.IfNoneMatch != nil && etagMatches(aws.ToString(params.IfNoneMatch), existing.etag) {
+			return nil, preconditionFailed()
+		}
+	}
+	object := &fakeObject{
+		body:         body,
+		etag:         fakeETag(body),
+		lastModified: time.Now().UTC(),
+		headers: fakeHeaders{
+			contentType:        params.ContentType,
+			cacheControl:       params.CacheControl,
+			contentLanguage:    params.ContentLanguage,
+			contentEncoding:    params.ContentEncoding,
+			contentDisposition: params.ContentDisposition,
+			expires:            params.Expires,
+			metadata:           copyMetadata(params.Metadata),
+			storageClass:       params.StorageClass,
+		},
+	}
+	if params.ChecksumAlgorithm == types.ChecksumAlgorithmSha256 || params.ChecksumSHA256 != nil {
+		sum := sha256.Sum256(body)
+		object.checksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	bucket.objects[aws.ToString(params.Key)] = object
+	return &s3.PutObjectOutput{ETag: aws.String(object.etag), ChecksumSHA256: object.checksumSHA256}, nil
+}
+
+func (fake *FakeS3) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	source, err := url.PathUnescape(strings.TrimPrefix(aws.ToString(params.CopySource), "/"))
+	if err != nil {
+		return nil, fakeError("InvalidArgument", "Invalid copy source encoding")
+	}
+	sourceBucket, sourceKey, _ := strings.Cut(source, "/")
+	object, err := fake.object(aws.String(sourceBucket), aws.String(sourceKey))
+	if err != nil {
+		return nil, err
+	}
+	if params.CopySourceIfMatch != nil && !etagMatches(aws.ToString(params.CopySourceIfMatch), object.etag) {
+		return nil, preconditionFailed()
+	}
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	headers := object.headers
+	headers.metadata = copyMetadata(headers.metadata)
+	if params.MetadataDirective == types.MetadataDirectiveReplace {
+		headers = fakeHeaders{
+			contentType:        params.ContentType,
+			cacheControl:       params.CacheControl,
+			contentLanguage:    params.ContentLanguage,
+			contentEncoding:    params.ContentEncoding,
+			contentDisposition: params.ContentDisposition,
+			expires:            params.Expires,
+			metadata:           copyMetadata(params.Metadata),
+		}
+	}
+	headers.storageClass = params.StorageClass
+	copied := &fakeObject{
+		body:           object.body,
+		etag:           object.etag,
+		lastModified:   time.Now().UTC(),
+		headers:        headers,
+		checksumSHA256: object.checksumSHA256,
+	}
+	bucket.objects[aws.ToString(params.Key)] = copied
+	return &s3.CopyObjectOutput{
+		CopyObjectResult: &types.CopyObjectResult{
+			ETag:         aws.String(copied.etag),
+			LastModified: aws.Time(copied.lastModified),
+		},
+	}, nil
+}
+
+func (fake *FakeS3) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	delete(bucket.objects, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (fake *FakeS3) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	bucket, err := fake.bucket(params.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	output := &s3.DeleteObjectsOutput{}
+	if params.Delete == nil {
+		return output, nil
+	}
+	for _, object := range params.Delete.Objects {
+		delete(bucket.objects, aws.ToString(object.Key))
+		if !aws.ToBool(params.Delete.Quiet) {
+			output.Deleted = append(output.Deleted, types.DeletedObject{Key: object.Key})
+		}
+	}
+	return output, nil
+}
+
+func (fake *FakeS3) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if _, err := fake.bucket(params.Bucket); err != nil {
+		return nil, err
+	}
+	fake.uploadID++
+	uploadID := fmt.Sprintf("fake-upload-%v", fake.uploadID)
+	fake.uploads[uploadID] = &fakeUpload{
+		bucket: aws.ToString(params.Bucket),
+		key:    aws.ToString(params.Key),
+		headers: fakeHeaders{
+			contentType:        params.ContentType,
+			cacheControl:       params.CacheControl,
+			contentLanguage:    params.ContentLanguage,
+			contentEncoding:    params.ContentEncoding,
+			contentDisposition: params.ContentDisposition,
+			expires:            params.Expires,
+			metadata:           copyMetadata(params.Metadata),
+			storageClass:       params.StorageClass,
+		},
+		parts:    map[int32][]byte{},
+		checksum: params.ChecksumAlgorithm,
+	}
+	return &s3.CreateMultipartUploadOutput{
+		Bucket:   params.Bucket,
+		Key:      params.Key,
+		UploadId: aws.String(uploadID),
+	}, nil
+}
+
+// upload returns the multipart upload with the given ID. The caller must hold the mutex.
+func (fake *FakeS3) upload(uploadID *string) (*fakeUpload, error) {
+	upload, found := fake.uploads[aws.ToString(uploadID)]
+	if !found {
+		return nil, &types.NoSuchUpload{Message: aws.String("The specified upload does not exist.")}
+	}
+	return upload, nil
+}
+
+func (fake *FakeS3) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	upload, err := fake.upload(params.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	upload.parts[aws.ToInt32(params.PartNumber)] = body
+	return &s3.UploadPartOutput{ETag: aws.String(fakeETag(body))}, nil
+}
+
+func (fake *FakeS3) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	upload, err := fake.upload(params.UploadId)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := fake.bucket(aws.String(upload.bucket))
+	if err != nil {
+		return nil, err
+	}
+	var body []byte
+	var parts []types.CompletedPart
+	if params.MultipartUpload != nil {
+		parts = params.MultipartUpload.Parts
+	}
+	digests := md5.New()
+	checksums := sha256.New()
+	for _, part := range parts {
+		data, found := upload.parts[aws.ToInt32(part.PartNumber)]
+		if !found {
+			return nil, fakeError("InvalidPart", "One or more of the specified parts could not be found.")
+		}
+		body = append(body, data...)
+		sum := md5.Sum(data)
+		digests.Write(sum[:])
+		checksum := sha256.Sum256(data)
+		checksums.Write(checksum[:])
+	}
+	object := &fakeObject{
+		body:         body,
+		etag:         fmt.Sprintf(`"%v-%v"`, hex.EncodeToString(digests.Sum(nil)), len(parts)),
+		lastModified: time.Now().UTC(),
+		headers:      upload.headers,
+		partsCount:   len(parts),
+	}
+	if upload.checksum == types.ChecksumAlgorithmSha256 {
+		object.checksumSHA256 = aws.String(base64.StdEncoding.EncodeToString(checksums.Sum(nil)))
+	}
+	bucket.objects[upload.key] = object
+	delete(fake.uploads, aws.ToString(params.UploadId))
+	return &s3.CompleteMultipartUploadOutput{
+		Bucket: aws.String(upload.bucket),
+		Key:    aws.String(upload.key),
+		ETag:   aws.String(object.etag),
+	}, nil
+}
+
+func (fake *FakeS3) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	if _, err := fake.upload(params.UploadId); err != nil {
+		return nil, err
+	}
+	delete(fake.uploads, aws.ToString(params.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
@@ -0,0 +1,192 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func putFakeObject(t *testing.T, fake *FakeS3, bucketName string, key string, body string) {
+	t.Helper()
+	_, err := fake.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(body),
+	})
+	if err != nil {
+		t.Fatalf("PutObject %v: %v", key, err)
+	}
+}
+
+func isPreconditionFailed(err error) bool {
+	var apiError smithy.APIError
+	return errors.As(err, &apiError) && apiError.ErrorCode() == "PreconditionFailed"
+}
+
+func TestFakeListObjectsV2Pages(t *testing.T) {
+	_, fake := newFakeService(t, "bucket")
+	for _, key := range []string{"a/", "a/1", "a/b/2", "a/c/3", "a/d", "b/4", "top"} {
+		putFakeObject(t, fake, "bucket", key, "x")
+	}
+
+	tests := []struct {
+		prefix   string
+		prefixes []string
+		keys     []string
+	}{
+		{"", []string{"a/", "b/"}, []string{"top"}},
+		{"a/", []string{"a/b/", "a/c/"}, []string{"a/", "a/1", "a/d"}},
+	}
+	for _, test := range tests {
+		// One entry per page exercises the continuation tokens after both objects
+		// and common prefixes.
+		paginator := s3.NewListObjectsV2Paginator(fake, &s3.ListObjectsV2Input{
+			Bucket:    aws.String("bucket"),
+			Prefix:    aws.String(test.prefix),
+			Delimiter: aws.String("/"),
+			MaxKeys:   aws.Int32(1),
+		})
+		var prefixes, keys []string
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(context.Background())
+			if err != nil {
+				t.Fatalf("prefix %q: %v", test.prefix, err)
+			}
+			if count := len(page.CommonPrefixes) + len(page.Contents); count > 1 {
+				t.Errorf("prefix %q: page has %v entries, want at most 1", test.prefix, count)
+			}
+			for _, commonPrefix := range page.CommonPrefixes {
+				prefixes = append(prefixes, aws.ToString(commonPrefix.Prefix))
+			}
+			for _, object := range page.Contents {
+				keys = append(keys, aws.ToString(object.Key))
+			}
+		}
+		if !slices.Equal(prefixes, test.prefixes) || !slices.Equal(keys, test.keys) {
+			t.Errorf("prefix %q: got prefixes %v and keys %v, want %v and %v",
+				test.prefix, prefixes, keys, test.prefixes, test.keys)
+		}
+	}
+
+	page, err := fake.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{
+		Bucket:  aws.String("bucket"),
+		MaxKeys: aws.Int32(0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.ToBool(page.IsTruncated) || len(page.Contents) > 0 {
+		t.Errorf("MaxKeys=0: got %v keys, truncated %v; want an empty final page",
+			len(page.Contents), aws.ToBool(page.IsTruncated))
+	}
+}
+
+func TestFakeNotFound(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newFakeService(t, "bucket")
+
+	exists, err := service.BucketExists("missing")
+	if err != nil || exists {
+		t.Errorf("BucketExists(missing) = %v, %v, want false, nil", exists, err)
+	}
+	exists, err = service.BucketExists("bucket")
+	if err != nil || !exists {
+		t.Errorf("BucketExists(bucket) = %v, %v, want true, nil", exists, err)
+	}
+
+	_, err = service.GetObjectInfo(ctx, "bucket", "missing")
+	var notFound *types.NotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("GetObjectInfo(missing) error = %v, want *types.NotFound", err)
+	}
+	_, err = service.OpenObject(ctx, "bucket", "missing")
+	var noSuchKey *types.NoSuchKey
+	if !errors.As(err, &noSuchKey) {
+		t.Errorf("OpenObject(missing) error = %v, want *types.NoSuchKey", err)
+	}
+}
+
+func TestFakeMultipartThroughUploadManager(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newFakeService(t, "bucket")
+	service.PartSize = minPartSize
+	data := bytes.Repeat([]byte("0123456789abcdef"), int(2*minPartSize/16)+1024)
+
+	if _, err := service.UploadBytes(ctx, "bucket", "large", data, UploadOptions{ContentType: "text/plain"}); err != nil {
+		t.Fatalf("UploadBytes: %v", err)
+	}
+	info, err := service.GetObjectInfo(ctx, "bucket", "large")
+	if err != nil {
+		t.Fatalf("GetObjectInfo: %v", err)
+	}
+	if !strings.HasSuffix(strings.Trim(info.ETag, `"`), "-3") {
+		t.Errorf("ETag = %v, want the multipart ETag of 3 parts", info.ETag)
+	}
+	if info.ContentType != "text/plain" {
+		t.Errorf("ContentType = %q, want text/plain", info.ContentType)
+	}
+	body, err := service.OpenObject(ctx, "bucket", "large")
+	if err != nil {
+		t.Fatalf("OpenObject: %v", err)
+	}
+	defer body.Close()
+	downloaded, err := io.ReadAll(body)
+	if err != nil || !bytes.Equal(downloaded, data) {
+		t.Errorf("downloaded %v bytes (err %v), want the %v uploaded", len(downloaded), err, len(data))
+	}
+}
+
+func TestFakePreconditions(t *testing.T) {
+	ctx := context.Background()
+	service, fake := newFakeService(t, "bucket")
+	putFakeObject(t, fake, "bucket", "key", "first")
+
+	_, err := fake.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String("bucket"),
+		Key:         aws.String("key"),
+		Body:        strings.NewReader("second"),
+		IfNoneMatch: aws.String("*"),
+	})
+	if !isPreconditionFailed(err) {
+		t.Errorf("PutObject with If-None-Match over an existing key: error = %v, want PreconditionFailed", err)
+	}
+	_, err = fake.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String("bucket"),
+		Key:         aws.String("new"),
+		Body:        strings.NewReader("second"),
+		IfNoneMatch: aws.String("*"),
+	})
+	if err != nil {
+		t.Errorf("PutObject with If-None-Match on a free key: %v", err)
+	}
+
+	_, err = fake.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String("bucket"),
+		Key:               aws.String("copy"),
+		CopySource:        aws.String(copySource("bucket", "key")),
+		CopySourceIfMatch: aws.String(`"not-the-etag"`),
+	})
+	if !isPreconditionFailed(err) {
+		t.Errorf("CopyObject with a stale source ETag: error = %v, want PreconditionFailed", err)
+	}
+	if err := service.SetMetadata(ctx, "bucket", "key", map[string]string{"a": "1"}, false); err != nil {
+		t.Errorf("SetMetadata with the current ETag: %v", err)
+	}
+
+	report, err := service.DryRunCheck(ctx, "bucket")
+	if err != nil {
+		t.Fatalf("DryRunCheck: %v", err)
+	}
+	if !report.ReadMetadata || !report.List || !report.Write || !report.Read || !report.Delete {
+		t.Errorf("DryRunCheck = %+v, want every permission", report)
+	}
+}
@@ -0,0 +1,176 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// RequestError wraps the error of a failed S3 request with the x-amz-request-id and
+// x-amz-id-2 values AWS support asks for when investigating it.
+type RequestError struct {
+	Err       error
+	RequestID string
+	HostID    string
+}
+
+// Error returns the text of the wrapped error followed by the IDs it doesn't already
+// show; the S3 response errors of the SDK show both.
+func (e *RequestError) Error() string {
+	text := e.Err.Error()
+	var missing []string
+	if !strings.Contains(text, e.RequestID) {
+		missing = append(missing, "requestID="+e.RequestID)
+	}
+	if !strings.Contains(text, e.HostID) {
+		missing = append(missing, "hostID="+e.HostID)
+	}
+	if len(missing) == 0 {
+		return text
+	}
+	return fmt.Sprintf("%v (%v)", text, strings.Join(missing, ", "))
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// RequestIDs returns the request ID and host ID of the S3 request behind an error
+// returned by this package. Both are empty when the request never reached S3.
+func RequestIDs(err error) (string, string) {
+	var requestError *RequestError
+	if errors.As(err, &requestError) {
+		return requestError.RequestID, requestError.HostID
+	}
+	return responseIDs(err, nil)
+}
+
+// responseIDs reads the request IDs from the SDK response error, falling back to the
+// response metadata for errors that don't carry them.
+func responseIDs(err error, metadata *middleware.Metadata) (string, string) {
+	var requestID, hostID string
+	var withRequestID interface{ ServiceRequestID() string }
+	if errors.As(err, &withRequestID) {
+		requestID = withRequestID.ServiceRequestID()
+	}
+	var withHostID interface{ ServiceHostID() string }
+	if errors.As(err, &withHostID) {
+		hostID = withHostID.ServiceHostID()
+	}
+	if metadata != nil {
+		if requestID == "" {
+			requestID, _ = awsmiddleware.GetRequestIDMetadata(*metadata)
+		}
+		if hostID == "" {
+			hostID, _ = s3.GetHostIDMetadata(*metadata)
+		}
+	}
+	return requestID, hostID
+}
+
+// withMiddleware returns a copy of options whose clients run the middleware of this
+// service on every request and resolve endpoints through it.
+func (service *s3Service) withMiddleware(options s3.Options) s3.Options {
+	options = options.Copy()
+	options.APIOptions = append(options.APIOptions, service.addMiddleware)
+	next := options.EndpointResolverV2
+	if next == nil {
+		next = s3.NewDefaultEndpointResolverV2()
+	}
+	options.EndpointResolverV2 = &regionResolver{service: service, next: next}
+	return options
+}
+
+func (service *s3Service) addMiddleware(stack *middleware.Stack) error {
+	err := stack.Initialize.Add(middleware.InitializeMiddlewareFunc("RequestIDs", attachRequestIDs), middleware.Before)
+	if err != nil {
+		return err
+	}
+	err = stack.Initialize.Add(middleware.InitializeMiddlewareFunc("CustomerKeyErrors", mapCustomerKeyError), middleware.After)
+	if err != nil {
+		return err
+	}
+	err = stack.Initialize.Add(middleware.InitializeMiddlewareFunc("Timeouts", service.applyTimeout), middleware.After)
+	if err != nil {
+		return err
+	}
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("ResultLog", service.logResult), middleware.After)
+}
+
+// attachRequestIDs wraps errors of failed requests in a *RequestError.
+func attachRequestIDs(ctx context.Context, input middleware.InitializeInput, next middleware.InitializeHandler) (
+	middleware.InitializeOutput, middleware.Metadata, error) {
+	output, metadata, err := next.HandleInitialize(ctx, input)
+	if err != nil {
+		requestID, hostID := responseIDs(err, &metadata)
+		if requestID != "" || hostID != "" {
+			err = &RequestError{Err: err, RequestID: requestID, HostID: hostID}
+		}
+	}
+	return output, metadata, err
+}
+
+// logResult emits one debug record per request through Logger with the operation,
+// bucket, key, byte count, duration and outcome. Only those fields are logged, never
+// credentials, headers or URLs.
+func (service *s3Service) logResult(ctx context.Context, input middleware.InitializeInput,
+	next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	logger := service.Logger
+	if logger == nil || !logger.Enabled(ctx, slog.LevelDebug) {
+		return next.HandleInitialize(ctx, input)
+	}
+	start := time.Now()
+	output, metadata, err := next.HandleInitialize(ctx, input)
+	bytes := int64Field(input.Parameters, "ContentLength")
+	if bytes == 0 {
+		bytes = int64Field(output.Result, "ContentLength")
+	}
+	attributes := []slog.Attr{
+		slog.String("operation", awsmiddleware.GetOperationName(ctx)),
+		slog.String("bucket", stringField(input.Parameters, "Bucket")),
+		slog.String("key", stringField(input.Parameters, "Key")),
+		slog.Int64("bytes", bytes),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		attributes = append(attributes, slog.String("outcome", "error"), slog.String("error", err.Error()))
+	} else {
+		attributes = append(attributes, slog.String("outcome", "ok"))
+	}
+	logger.LogAttrs(ctx, slog.LevelDebug, "s3 request", attributes...)
+	return output, metadata, err
+}
+
+// stringField reads a *string field of an SDK input or output struct by name.
+func stringField(value any, name string) string {
+	field := structField(value, name)
+	if !field.IsValid() || field.Kind() != reflect.Pointer || field.IsNil() || field.Elem().Kind() != reflect.String {
+		return ""
+	}
+	return field.Elem().String()
+}
+
+// int64Field reads an *int64 field of an SDK input or output struct by name.
+func int64Field(value any, name string) int64 {
+	field := structField(value, name)
+	if !field.IsValid() || field.Kind() != reflect.Pointer || field.IsNil() || field.Elem().Kind() != reflect.Int64 {
+		return 0
+	}
+	return field.Elem().Int()
+}
+
+func structField(value any, name string) reflect.Value {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return v.Elem().FieldByName(name)
+}
@@ -0,0 +1,63 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// stubTransport answers every request with the same status, headers and body.
+type stubTransport struct {
+	status int
+	header http.Header
+	body   string
+}
+
+func (stub stubTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: stub.status,
+		Header:     stub.header.Clone(),
+		Body:       io.NopCloser(strings.NewReader(stub.body)),
+		Request:    request,
+	}, nil
+}
+
+// newStubService returns a service whose requests are all answered by transport.
+func newStubService(transport http.RoundTripper) *s3Service {
+	return NewS3Service(s3.Options{
+		Region:           "us-east-1",
+		Credentials:      credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		HTTPClient:       &http.Client{Transport: transport},
+		RetryMaxAttempts: 1,
+	})
+}
+
+func TestRequestErrorShowsIDsOnce(t *testing.T) {
+	service := newStubService(stubTransport{status: 500, header: http.Header{
+		"X-Amz-Request-Id": {"RID"},
+		"X-Amz-Id-2":       {"HID"},
+	}})
+	_, err := service.GetObjectInfo(context.Background(), "bucket", "key")
+	var requestError *RequestError
+	if !errors.As(err, &requestError) {
+		t.Fatalf("error %v is not a *RequestError", err)
+	}
+	if requestID, hostID := RequestIDs(err); requestID != "RID" || hostID != "HID" {
+		t.Errorf("RequestIDs = %q, %q, want RID, HID", requestID, hostID)
+	}
+	text := err.Error()
+	if strings.Count(text, "RID") != 1 || strings.Count(text, "HID") != 1 {
+		t.Errorf("%q should show each ID exactly once", text)
+	}
+
+	plain := &RequestError{Err: errors.New("boom"), RequestID: "RID", HostID: "HID"}
+	if plain.Error() != "boom (requestID=RID, hostID=HID)" {
+		t.Errorf("Error() = %q, want the IDs appended", plain.Error())
+	}
+}
@@ -0,0 +1,86 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// defaultRetryAttempts is the number of attempts of a RetryPolicy that sets none.
+const defaultRetryAttempts = 3
+
+// RetryPolicy configures Retry.
+type RetryPolicy struct {
+	// Attempts is the most times fn is called. Zero means defaultRetryAttempts.
+	Attempts int
+	// BaseDelay is the delay before the first retry; it doubles on every further
+	// retry, up to MaxDelay when that is set.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter waits a random duration between zero and the computed delay instead,
+	// so clients failing together don't retry together.
+	Jitter bool
+	// Retryable decides which errors are worth another attempt. Nil means IsRetryable.
+	Retryable func(error) bool
+}
+
+// IsRetryable reports whether an error is likely to go away on its own: a body that
+// broke off midway, or anything the SDK itself treats as transient (throttling, 5xx
+// responses, connection resets and timeouts). Canceled or expired contexts are not.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if isPartialRead(err) {
+		return true
+	}
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// Retry calls fn until it succeeds, fails with an error the policy doesn't retry, runs
+// out of attempts or ctx is done, and returns the last error. It is the same retry
+// loop the service uses internally, for composing several calls into one operation
+// that is retried as a whole.
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	attempts := policy.Attempts
+	if attempts <= 0 {
+		attempts = defaultRetryAttempts
+	}
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	var err error
+	delay := policy.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+		wait := delay
+		if policy.Jitter && wait > 0 {
+			wait = time.Duration(rand.Int63n(int64(wait) + 1))
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay *= 2
+		if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+	}
+}
+
+// withRetry calls fn until it succeeds, fails with an error shouldRetry rejects,
+// or runs out of attempts. The delay between attempts doubles each time.
+func withRetry(ctx context.Context, attempts int, backoff time.Duration,
+	shouldRetry func(error) bool, fn func() error) error {
+	return Retry(ctx, RetryPolicy{Attempts: attempts, BaseDelay: backoff, Retryable: shouldRetry},
+		func(context.Context) error { return fn() })
+}
@@ -6,41 +6,207 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"log/slog"
 	"os"
+	"path"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
+	"golang.org/x/sync/semaphore"
 )
 
 type s3Service struct {
-	s3Client *s3.Client
+	s3Client s3API
+	options  s3.Options
+
+	// Logger receives a debug record for every S3 request the service makes. Nil
+	// disables these records; the error logs of each method are unaffected.
+	Logger *slog.Logger
+
+	// DownloadAttempts is how many times a download is attempted when reading
+	// the object body fails midway. Zero means defaultDownloadAttempts.
+	DownloadAttempts int
+	// DownloadBackoff is the delay before the first download retry; it doubles
+	// on every further retry. Zero means defaultDownloadBackoff.
+	DownloadBackoff time.Duration
+
+	// PartSize is the size of the parts of multipart uploads. Zero means
+	// defaultPartSize. MultipartThreshold is the body size above which uploads
+	// switch from a single PutObject to multipart; zero means PartSize. The
+	// threshold can't be below the part size.
+	PartSize           int64
+	MultipartThreshold int64
+
+	// MultipartAttempts is how many times UploadLargeObject starts a new multipart
+	// upload when the previous one was lost. Zero means defaultMultipartAttempts.
+	MultipartAttempts int
+
+	// KeepPartial keeps the local file of a download that failed midway instead of
+	// deleting it. ResumeDownloadFile always keeps partial files.
+	KeepPartial bool
+
+	// SequentialPrefetch is how many ranges DownloadSequential fetches ahead of the
+	// one being written. Zero means defaultConcurrency.
+	SequentialPrefetch int
+
+	// VerifyCopies makes copy methods wait until the destination object answers
+	// HeadObject before returning, which guards against consistency lag on
+	// S3-compatible stores. CopyVerifyTimeout bounds the wait; zero means
+	// defaultCopyVerifyTimeout.
+	VerifyCopies      bool
+	CopyVerifyTimeout time.Duration
+
+	// MaxConcurrency caps the number of concurrent requests across every batch and
+	// transfer operation of this service. Per-operation concurrency is clamped to it.
+	// Zero means no cap. It must be set before the service is first used.
+	MaxConcurrency int64
+	limiterOnce    sync.Once
+	limiter        *semaphore.Weighted
+
+	// InfoCacheSize is how many GetObjectInfo results are kept in memory. Entries
+	// are dropped when the object is written, copied over or deleted through this
+	// service. Zero disables the cache. It must be set before the service is first used.
+	InfoCacheSize int
+	// InfoCacheTTL is how long a cached GetObjectInfo result stays valid. Zero means
+	// entries only leave the cache when evicted or invalidated.
+	InfoCacheTTL  time.Duration
+	infoCacheOnce sync.Once
+	infoCache     *infoCache
+
+	// ListTimeout, MetadataTimeout and TransferTimeout bound each request by category:
+	// listings (per page, so long listings still finish), HeadObject-style metadata
+	// reads, and requests that move object data (GetObject including reading its body,
+	// PutObject, each part of a multipart upload, CopyObject). Zero means no deadline
+	// beyond the caller's context.
+	ListTimeout     time.Duration
+	MetadataTimeout time.Duration
+	TransferTimeout time.Duration
+
+	// AutoRegion sends the requests for each bucket to the Region the bucket lives
+	// in, which is looked up with GetBucketLocation on first use and cached, instead
+	// of failing with PermanentRedirect outside the configured Region. It has no
+	// effect on services created with NewS3ServiceWithClient.
+	AutoRegion    bool
+	regionsMutex  sync.Mutex
+	bucketRegions map[string]bucketRegionEntry
+
+	uploadsMutex sync.Mutex
+	openUploads  map[string]openUpload
 }
 
+const (
+	defaultDownloadAttempts  = 3
+	defaultDownloadBackoff   = 500 * time.Millisecond
+	defaultMultipartAttempts = 3
+	// defaultPartSize is the default part size of multipart uploads and downloads.
+	defaultPartSize int64 = 10 * 1024 * 1024
+)
+
 var S3 s3Service
 
 func (service *s3Service) NewClient(options s3.Options) {
-	service.s3Client = s3.New(options)
+	service.options = options
+	service.s3Client = s3.New(service.withMiddleware(options))
+}
+
+// NewS3Service creates a standalone service with its own client, for callers that
+// need more than the package-level S3 service (e.g. one client per region).
+func NewS3Service(options s3.Options) *s3Service {
+	service := &s3Service{}
+	service.NewClient(options)
+	return service
+}
+
+// NewS3ServiceWithClient creates a service on top of an existing client, such as the
+// in-memory one returned by NewFakeS3. Operations that need the client options, like
+// presigning, aren't available on such a service.
+func NewS3ServiceWithClient(client s3API) *s3Service {
+	return &s3Service{s3Client: client}
+}
+
+func (service *s3Service) downloadAttempts() int {
+	if service.DownloadAttempts > 0 {
+		return service.DownloadAttempts
+	}
+	return defaultDownloadAttempts
+}
+
+func (service *s3Service) downloadBackoff() time.Duration {
+	if service.DownloadBackoff > 0 {
+		return service.DownloadBackoff
+	}
+	return defaultDownloadBackoff
+}
+
+// partialReadError reports that an object body stopped streaming after some bytes
+// had already been written locally.
+type partialReadError struct {
+	written int64
+	err     error
+}
+
+func (e *partialReadError) Error() string {
+	return fmt.Sprintf("read failed after %v bytes: %v", e.written, e.err)
+}
+
+func (e *partialReadError) Unwrap() error {
+	return e.err
+}
+
+func isPartialRead(err error) bool {
+	var partialRead *partialReadError
+	return errors.As(err, &partialRead)
 }
 
 // ListBuckets lists the buckets in the current account.
 func (service *s3Service) ListBuckets() ([]types.Bucket, error) {
-	result, err := service.s3Client.ListBuckets(context.TODO(), &s3.ListBucketsInput{})
+	return service.ListBucketsFiltered(context.TODO(), "")
+}
+
+// listBucketsPageSize is how many buckets are requested per ListBuckets page.
+const listBucketsPageSize = 1000
+
+// ListBucketsFiltered lists the buckets in the current account whose names match
+// pattern, following ListBuckets pagination. The pattern uses path.Match syntax
+// (e.g. "logs-*"); an empty pattern matches every bucket.
+func (service *s3Service) ListBucketsFiltered(ctx context.Context, pattern string) ([]types.Bucket, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		log.Printf("Couldn't list buckets matching %q. Here's why: %v\n", pattern, err)
+		return nil, err
+	}
 	var buckets []types.Bucket
-	if err != nil {
-		log.Printf("Couldn't list buckets for your account. Here's why: %v\n", err)
-	} else {
-		buckets = result.Buckets
+	paginator := s3.NewListBucketsPaginator(service.s3Client, &s3.ListBucketsInput{
+		MaxBuckets: aws.Int32(listBucketsPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Couldn't list buckets for your account. Here's why: %v\n", err)
+			return nil, err
+		}
+		for _, bucket := range page.Buckets {
+			if matched, _ := path.Match(pattern, aws.ToString(bucket.Name)); pattern == "" || matched {
+				buckets = append(buckets, bucket)
+			}
+		}
 	}
-	return buckets, err
+	return buckets, nil
 }
 
 // BucketExists checks whether a bucket exists in the current account.
 func (service *s3Service) BucketExists(bucketName string) (bool, error) {
-	_, err := service.s3Client.HeadBucket(context.TODO(), &s3.HeadBucketInput{
+	return service.bucketExists(context.TODO(), bucketName)
+}
+
+func (service *s3Service) bucketExists(ctx context.Context, bucketName string) (bool, error) {
+	_, err := service.s3Client.HeadBucket(ctx, &s3.HeadBucketInput{
 		Bucket: aws.String(bucketName),
 	})
 	exists := true
@@ -64,14 +230,42 @@ func (service *s3Service) BucketExists(bucketName string) (bool, error) {
 	return exists, err
 }
 
+// GetBucketRegion resolves the Region a bucket lives in using GetBucketLocation.
+func (service *s3Service) GetBucketRegion(ctx context.Context, bucketName string) (string, error) {
+	result, err := service.s3Client.GetBucketLocation(ctx, &s3.GetBucketLocationInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		log.Printf("Couldn't get the location of bucket %v. Here's why: %v\n", bucketName, err)
+		return "", err
+	}
+	// Buckets in us-east-1 report an empty location constraint, and very old
+	// eu-west-1 buckets still report the legacy "EU" value.
+	switch result.LocationConstraint {
+	case "":
+		return "us-east-1", nil
+	case types.BucketLocationConstraintEu:
+		return "eu-west-1", nil
+	default:
+		return string(result.LocationConstraint), nil
+	}
+}
+
 // CreateBucket creates a bucket with the specified name in the specified Region.
-func (service *s3Service) CreateBucket(name string, region string) error {
-	_, err := service.s3Client.CreateBucket(context.TODO(), &s3.CreateBucketInput{
+// The optional CreateBucketOptions set the ACL and object ownership of the bucket.
+func (service *s3Service) CreateBucket(name string, region string, options ...CreateBucketOptions) error {
+	input := &s3.CreateBucketInput{
 		Bucket: aws.String(name),
 		CreateBucketConfiguration: &types.CreateBucketConfiguration{
 			LocationConstraint: types.BucketLocationConstraint(region),
 		},
-	})
+	}
+	var bucketOptions CreateBucketOptions
+	if len(options) > 0 {
+		bucketOptions = options[0]
+	}
+	bucketOptions.applyTo(input)
+	_, err := service.s3Client.CreateBucket(context.TODO(), input)
 	if err != nil {
 		log.Printf("Couldn't create bucket %v in Region %v. Here's why: %v\n",
 			name, region, err)
@@ -80,52 +274,143 @@ func (service *s3Service) CreateBucket(name string, region string) error {
 }
 
 // UploadFile reads from a file and puts the data into an object in a bucket.
-func (service *s3Service) UploadFile(bucketName string, objectKey string, fileName string) error {
+// It returns the VersionId of the new object, which is empty on unversioned buckets.
+func (service *s3Service) UploadFile(bucketName string, objectKey string, fileName string) (string, error) {
+	return service.UploadFileWithOptions(context.TODO(), bucketName, objectKey, fileName, UploadOptions{})
+}
+
+// UploadFileWithOptions uploads a file like UploadFile, also setting the object
+// headers given in options.
+func (service *s3Service) UploadFileWithOptions(ctx context.Context, bucketName string, objectKey string,
+	fileName string, options UploadOptions) (string, error) {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}
+	options.applyTo(input)
+	return service.putFile(ctx, input, fileName)
+}
+
+// putFile uploads a file with a single PutObject described by input, whose Body it sets.
+func (service *s3Service) putFile(ctx context.Context, input *s3.PutObjectInput, fileName string) (string, error) {
+	bucketName, objectKey := aws.ToString(input.Bucket), aws.ToString(input.Key)
+	var versionId string
 	file, err := os.Open(fileName)
 	if err != nil {
 		log.Printf("Couldn't open file %v to upload. Here's why: %v\n", fileName, err)
 	} else {
 		defer file.Close()
-		_, err = service.s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(objectKey),
-			Body:   file,
-		})
+		input.Body = file
+		var result *s3.PutObjectOutput
+		result, err = service.s3Client.PutObject(ctx, input)
+		service.invalidateInfo(bucketName, objectKey)
 		if err != nil {
 			log.Printf("Couldn't upload file %v to %v:%v. Here's why: %v\n",
 				fileName, bucketName, objectKey, err)
+		} else {
+			versionId = aws.ToString(result.VersionId)
 		}
 	}
-	return err
+	return versionId, err
 }
 
 // UploadLargeObject uses an upload manager to upload data to an object in a bucket.
 // The upload manager breaks large data into parts and uploads the parts concurrently.
-func (service *s3Service) UploadLargeObject(bucketName string, objectKey string, largeObject []byte) error {
-	largeBuffer := bytes.NewReader(largeObject)
-	var partMiBs int64 = 10
-	uploader := manager.NewUploader(service.s3Client, func(u *manager.Uploader) {
-		u.PartSize = partMiBs * 1024 * 1024
-	})
-	_, err := uploader.Upload(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKey),
-		Body:   largeBuffer,
-	})
+// When the multipart upload itself is lost midway (NoSuchUpload, e.g. because it
+// expired or was aborted elsewhere), it is aborted and restarted from scratch up to
+// MultipartAttempts times; other errors are returned right away.
+// It returns the VersionId of the new object, which is empty on unversioned buckets.
+func (service *s3Service) UploadLargeObject(bucketName string, objectKey string, largeObject []byte) (string, error) {
+	ctx := context.TODO()
+	uploader, release, err := service.newUploader(ctx)
 	if err != nil {
-		log.Printf("Couldn't upload large object to %v:%v. Here's why: %v\n",
-			bucketName, objectKey, err)
+		return "", err
 	}
+	defer release()
+	var versionId string
+	err = withRetry(ctx, service.multipartAttempts(), 0, isMultipartStateError, func() error {
+		result, err := uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(objectKey),
+			Body:   bytes.NewReader(largeObject),
+		})
+		service.invalidateInfo(bucketName, objectKey)
+		if err != nil {
+			log.Printf("Couldn't upload large object to %v:%v. Here's why: %v\n",
+				bucketName, objectKey, err)
+			service.abortFailedUpload(ctx, bucketName, objectKey, err)
+			return err
+		}
+		versionId = aws.ToString(result.VersionID)
+		return nil
+	})
+	return versionId, err
+}
 
-	return err
+func (service *s3Service) multipartAttempts() int {
+	if service.MultipartAttempts > 0 {
+		return service.MultipartAttempts
+	}
+	return defaultMultipartAttempts
+}
+
+// isMultipartStateError reports whether an upload failed because S3 no longer knows
+// its multipart upload, which only a fresh CreateMultipartUpload can recover from.
+func isMultipartStateError(err error) bool {
+	var noSuchUpload *types.NoSuchUpload
+	var apiError smithy.APIError
+	return errors.As(err, &noSuchUpload) || (errors.As(err, &apiError) && apiError.ErrorCode() == "NoSuchUpload")
+}
+
+// abortFailedUpload aborts the multipart upload behind a failed upload, if any, so
+// its parts don't linger and keep costing storage.
+func (service *s3Service) abortFailedUpload(ctx context.Context, bucketName string, objectKey string, err error) {
+	var failure manager.MultiUploadFailure
+	if !errors.As(err, &failure) || failure.UploadID() == "" {
+		return
+	}
+	_, abortErr := service.uploadClient().AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(objectKey),
+		UploadId: aws.String(failure.UploadID()),
+	})
+	if abortErr != nil && !isMultipartStateError(abortErr) {
+		log.Printf("Couldn't abort multipart upload %v of %v:%v. Here's why: %v\n",
+			failure.UploadID(), bucketName, objectKey, abortErr)
+	}
 }
 
 // DownloadFile gets an object from a bucket and stores it in a local file.
+// If reading the body fails midway, the incomplete file is deleted and the whole
+// download is retried up to DownloadAttempts times with exponential backoff.
 func (service *s3Service) DownloadFile(bucketName string, objectKey string, fileName string) error {
-	result, err := service.s3Client.GetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKey),
+	return service.downloadFile(context.TODO(), bucketName, objectKey, fileName)
+}
+
+func (service *s3Service) downloadFile(ctx context.Context, bucketName string, objectKey string, fileName string) error {
+	return service.DownloadFileWithOptions(ctx, bucketName, objectKey, fileName, DownloadOptions{})
+}
+
+// DownloadFileWithOptions downloads an object to a local file like DownloadFile,
+// with the read settings given in options.
+func (service *s3Service) DownloadFileWithOptions(ctx context.Context, bucketName string, objectKey string,
+	fileName string, options DownloadOptions) error {
+	return withRetry(ctx, service.downloadAttempts(), service.downloadBackoff(), isPartialRead, func() error {
+		input := &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(objectKey),
+		}
+		options.applyTo(input)
+		return service.downloadOnce(ctx, input, fileName)
 	})
+}
+
+// downloadOnce writes the object selected by input to fileName. Unless KeepPartial is
+// set, the file is removed again when anything fails after it was created, including
+// ctx being cancelled mid-body, so a truncated file is never left behind.
+func (service *s3Service) downloadOnce(ctx context.Context, input *s3.GetObjectInput, fileName string) (err error) {
+	bucketName, objectKey := aws.ToString(input.Bucket), aws.ToString(input.Key)
+	result, err := service.s3Client.GetObject(ctx, input)
 	if err != nil {
 		log.Printf("Couldn't get object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
 		return err
@@ -136,25 +421,139 @@ func (service *s3Service) DownloadFile(bucketName string, objectKey string, file
 		log.Printf("Couldn't create file %v. Here's why: %v\n", fileName, err)
 		return err
 	}
-	defer file.Close()
-	body, err := io.ReadAll(result.Body)
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil && !service.KeepPartial {
+			os.Remove(fileName)
+		}
+	}()
+	written, err := io.Copy(file, result.Body)
 	if err != nil {
-		log.Printf("Couldn't read object body from %v. Here's why: %v\n", objectKey, err)
+		log.Printf("Couldn't read object body from %v after %v bytes. Here's why: %v\n",
+			objectKey, written, err)
+		return &partialReadError{written: written, err: err}
 	}
-	_, err = file.Write(body)
-	return err
+	return nil
+}
+
+// resumeETagSuffix names the file next to a resumable download that records the ETag
+// of the object version its bytes came from.
+const resumeETagSuffix = ".etag"
+
+// ResumeDownloadFile downloads an object into a local file, continuing from the bytes
+// already present in the file instead of starting over. Read errors are retried like
+// in DownloadFile, but each retry resumes from where the previous attempt stopped and
+// the partial file is always kept.
+//
+// The ETag of the object is recorded next to the file (fileName + ".etag") while the
+// download is incomplete, and every ranged GET is pinned to it with IfMatch, so bytes
+// of two object versions are never mixed: when the object changed since the partial
+// file was written, or the file has no recorded ETag or is longer than the object, it
+// is truncated and downloaded again from the start. Success means the file size
+// equals the object size.
+func (service *s3Service) ResumeDownloadFile(ctx context.Context, bucketName string, objectKey string, fileName string) error {
+	head, err := service.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		log.Printf("Couldn't get info of object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return err
+	}
+	etag, size := aws.ToString(head.ETag), aws.ToInt64(head.ContentLength)
+	etagFile := fileName + resumeETagSuffix
+	recorded, err := os.ReadFile(etagFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Couldn't read file %v. Here's why: %v\n", etagFile, err)
+		return err
+	}
+	restart := string(recorded) != etag
+	if restart {
+		if err = os.WriteFile(etagFile, []byte(etag), 0644); err != nil {
+			log.Printf("Couldn't write file %v. Here's why: %v\n", etagFile, err)
+			return err
+		}
+	}
+
+	err = withRetry(ctx, service.downloadAttempts(), service.downloadBackoff(), isPartialRead, func() error {
+		file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Printf("Couldn't open file %v. Here's why: %v\n", fileName, err)
+			return err
+		}
+		defer file.Close()
+		stat, err := file.Stat()
+		if err != nil {
+			log.Printf("Couldn't stat file %v. Here's why: %v\n", fileName, err)
+			return err
+		}
+		offset := stat.Size()
+		if restart || offset > size {
+			if err = file.Truncate(0); err != nil {
+				log.Printf("Couldn't truncate file %v. Here's why: %v\n", fileName, err)
+				return err
+			}
+			offset, restart = 0, false
+		}
+		if offset == size {
+			return nil
+		}
+		if _, err = file.Seek(offset, io.SeekStart); err != nil {
+			log.Printf("Couldn't seek in file %v. Here's why: %v\n", fileName, err)
+			return err
+		}
+		input := &s3.GetObjectInput{
+			Bucket:  aws.String(bucketName),
+			Key:     aws.String(objectKey),
+			IfMatch: aws.String(etag),
+		}
+		if offset > 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%v-", offset))
+		}
+		result, err := service.s3Client.GetObject(ctx, input)
+		if err != nil {
+			log.Printf("Couldn't get object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+			return err
+		}
+		defer result.Body.Close()
+		written, err := io.Copy(file, result.Body)
+		if err == nil && offset+written != size {
+			err = fmt.Errorf("got %v of %v bytes", offset+written, size)
+		}
+		if err != nil {
+			log.Printf("Couldn't read object body from %v after %v bytes. Here's why: %v\n",
+				objectKey, offset+written, err)
+			return &partialReadError{written: offset + written, err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(etagFile); err != nil {
+		log.Printf("Couldn't remove file %v. Here's why: %v\n", etagFile, err)
+	}
+	return nil
 }
 
 // DownloadLargeObject uses a download manager to download an object from a bucket.
 // The download manager gets the data in parts and writes them to a buffer until all of
 // the data has been downloaded.
 func (service *s3Service) DownloadLargeObject(bucketName string, objectKey string) ([]byte, error) {
-	var partMiBs int64 = 10
+	ctx := context.TODO()
 	downloader := manager.NewDownloader(service.s3Client, func(d *manager.Downloader) {
-		d.PartSize = partMiBs * 1024 * 1024
+		d.PartSize = defaultPartSize
+		d.Concurrency = service.clampConcurrency(ctx, d.Concurrency)
 	})
+	release, err := service.acquire(ctx, downloader.Concurrency)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	buffer := manager.NewWriteAtBuffer([]byte{})
-	_, err := downloader.Download(context.TODO(), buffer, &s3.GetObjectInput{
+	_, err = downloader.Download(ctx, buffer, &s3.GetObjectInput{
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(objectKey),
 	})
@@ -166,17 +565,22 @@ func (service *s3Service) DownloadLargeObject(bucketName string, objectKey strin
 }
 
 // CopyToFolder copies an object in a bucket to a subfolder in the same bucket.
+// When VerifyCopies is set, it also waits for the copy to become readable.
 func (service *s3Service) CopyToFolder(bucketName string, objectKey string, folderName string) error {
-	_, err := service.s3Client.CopyObject(context.TODO(), &s3.CopyObjectInput{
+	ctx := context.TODO()
+	destinationKey := fmt.Sprintf("%v/%v", folderName, objectKey)
+	_, err := service.s3Client.CopyObject(ctx, &s3.CopyObjectInput{
 		Bucket:     aws.String(bucketName),
-		CopySource: aws.String(fmt.Sprintf("%v/%v", bucketName, objectKey)),
-		Key:        aws.String(fmt.Sprintf("%v/%v", folderName, objectKey)),
+		CopySource: aws.String(copySource(bucketName, objectKey)),
+		Key:        aws.String(destinationKey),
 	})
+	service.invalidateInfo(bucketName, destinationKey)
 	if err != nil {
 		log.Printf("Couldn't copy object from %v:%v to %v:%v/%v. Here's why: %v\n",
 			bucketName, objectKey, bucketName, folderName, objectKey, err)
+		return err
 	}
-	return err
+	return service.verifyCopy(ctx, bucketName, destinationKey, nil)
 }
 
 // ListObjects lists the objects in a bucket.
@@ -193,22 +597,67 @@ func (service *s3Service) ListObjects(bucketName string) ([]types.Object, error)
 	return contents, err
 }
 
-// DeleteObjects deletes a list of objects from a bucket.
-func (service *s3Service) DeleteObjects(bucketName string, objectKeys []string) error {
+// DeleteObjects deletes a list of objects from a bucket. The keys name no version, so
+// on an object-lock bucket this only adds delete markers and BypassGovernanceRetention
+// in the optional DeleteOptions has no effect; use DeleteObjectVersion to remove
+// locked versions.
+func (service *s3Service) DeleteObjects(bucketName string, objectKeys []string, options ...DeleteOptions) error {
+	deleteOptions := firstDeleteOptions(options)
 	var objectIds []types.ObjectIdentifier
 	for _, key := range objectKeys {
 		objectIds = append(objectIds, types.ObjectIdentifier{Key: aws.String(key)})
 	}
 	_, err := service.s3Client.DeleteObjects(context.TODO(), &s3.DeleteObjectsInput{
-		Bucket: aws.String(bucketName),
-		Delete: &types.Delete{Objects: objectIds},
+		Bucket:                    aws.String(bucketName),
+		Delete:                    &types.Delete{Objects: objectIds},
+		BypassGovernanceRetention: deleteOptions.bypass(),
 	})
+	for _, key := range objectKeys {
+		service.invalidateInfo(bucketName, key)
+	}
 	if err != nil {
+		err = deleteOptions.mapBypassError(err)
 		log.Printf("Couldn't delete objects from bucket %v. Here's why: %v\n", bucketName, err)
 	}
 	return err
 }
 
+// DeleteResult describes what a single-object delete did on a versioned bucket.
+type DeleteResult struct {
+	// VersionId is the version that was deleted or, when DeleteMarker is true,
+	// the version of the delete marker that was created.
+	VersionId    string
+	DeleteMarker bool
+}
+
+// DeleteObject deletes a single object and reports the version information S3
+// returns for it. Like in DeleteObjects, BypassGovernanceRetention in the optional
+// DeleteOptions has no effect on this delete by key.
+func (service *s3Service) DeleteObject(ctx context.Context, bucketName string, objectKey string,
+	options ...DeleteOptions) (*DeleteResult, error) {
+	return service.deleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}, firstDeleteOptions(options))
+}
+
+func (service *s3Service) deleteObject(ctx context.Context, input *s3.DeleteObjectInput,
+	options DeleteOptions) (*DeleteResult, error) {
+	bucketName, objectKey := aws.ToString(input.Bucket), aws.ToString(input.Key)
+	input.BypassGovernanceRetention = options.bypass()
+	result, err := service.s3Client.DeleteObject(ctx, input)
+	service.invalidateInfo(bucketName, objectKey)
+	if err != nil {
+		err = options.mapBypassError(err)
+		log.Printf("Couldn't delete object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return nil, err
+	}
+	return &DeleteResult{
+		VersionId:    aws.ToString(result.VersionId),
+		DeleteMarker: aws.ToBool(result.DeleteMarker),
+	}, nil
+}
+
 // DeleteBucket deletes a bucket. The bucket must be empty or an error is returned.
 func (service *s3Service) DeleteBucket(bucketName string) error {
 	_, err := service.s3Client.DeleteBucket(context.TODO(), &s3.DeleteBucketInput{
@@ -0,0 +1,105 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ThroughputResult is the outcome of a transfer benchmark.
+type ThroughputResult struct {
+	Bytes        int64
+	Duration     time.Duration
+	MiBPerSecond float64
+}
+
+func newThroughputResult(bytes int64, duration time.Duration) *ThroughputResult {
+	return &ThroughputResult{
+		Bytes:        bytes,
+		Duration:     duration,
+		MiBPerSecond: float64(bytes) / (1024 * 1024) / duration.Seconds(),
+	}
+}
+
+// uploadRandom streams sizeBytes of random data to an object through the upload
+// manager, without ever holding the whole payload in memory.
+func (service *s3Service) uploadRandom(ctx context.Context, bucketName string, objectKey string, sizeBytes int64) error {
+	uploader, release, err := service.newUploader(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+		Body:   io.LimitReader(rand.Reader, sizeBytes),
+	})
+	service.invalidateInfo(bucketName, objectKey)
+	if err != nil {
+		log.Printf("Couldn't upload benchmark object to %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+	}
+	return err
+}
+
+// deleteBenchmarkObject removes the object of a benchmark, even when ctx was canceled,
+// and joins the failure to delete it into err.
+func (service *s3Service) deleteBenchmarkObject(ctx context.Context, bucketName string, objectKey string, err *error) {
+	_, deleteErr := service.DeleteObject(context.WithoutCancel(ctx), bucketName, objectKey)
+	*err = errors.Join(*err, deleteErr)
+}
+
+// BenchmarkUpload measures how fast sizeBytes of random data upload to an object.
+// The object gets a random suffix after objectKey, so existing objects are never
+// overwritten, and is deleted afterwards. When only that cleanup fails, the result is
+// returned along with the error.
+func (service *s3Service) BenchmarkUpload(ctx context.Context, bucketName string, objectKey string,
+	sizeBytes int64) (result *ThroughputResult, err error) {
+	objectKey, err = randomKey(objectKey, "bench")
+	if err != nil {
+		return nil, err
+	}
+	defer service.deleteBenchmarkObject(ctx, bucketName, objectKey, &err)
+	start := time.Now()
+	if err = service.uploadRandom(ctx, bucketName, objectKey, sizeBytes); err != nil {
+		return nil, err
+	}
+	return newThroughputResult(sizeBytes, time.Since(start)), nil
+}
+
+// BenchmarkDownload uploads sizeBytes of random data to an object, then measures how
+// fast it downloads. Only the download is timed. The object is named and cleaned up
+// like in BenchmarkUpload.
+func (service *s3Service) BenchmarkDownload(ctx context.Context, bucketName string, objectKey string,
+	sizeBytes int64) (result *ThroughputResult, err error) {
+	objectKey, err = randomKey(objectKey, "bench")
+	if err != nil {
+		return nil, err
+	}
+	defer service.deleteBenchmarkObject(ctx, bucketName, objectKey, &err)
+	if err = service.uploadRandom(ctx, bucketName, objectKey, sizeBytes); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	output, err := service.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		log.Printf("Couldn't get benchmark object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return nil, err
+	}
+	defer output.Body.Close()
+	read, err := io.Copy(io.Discard, output.Body)
+	if err != nil {
+		log.Printf("Couldn't read benchmark object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return nil, err
+	}
+	return newThroughputResult(read, time.Since(start)), nil
+}
@@ -0,0 +1,33 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestBenchmarksKeepExistingObjects(t *testing.T) {
+	ctx := context.Background()
+	service, fake := newFakeService(t, "bucket")
+	putFakeObject(t, fake, "bucket", "key", "precious")
+
+	if _, err := service.BenchmarkUpload(ctx, "bucket", "key", 1024); err != nil {
+		t.Fatalf("BenchmarkUpload: %v", err)
+	}
+	result, err := service.BenchmarkDownload(ctx, "bucket", "key", 1024)
+	if err != nil || result.Bytes != 1024 {
+		t.Fatalf("BenchmarkDownload = %+v, %v, want 1024 bytes", result, err)
+	}
+	objects, err := service.ListObjects("bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || aws.ToString(objects[0].Key) != "key" {
+		t.Fatalf("bucket holds %v objects after the benchmarks, want only the original", len(objects))
+	}
+	info, err := service.GetObjectInfo(ctx, "bucket", "key")
+	if err != nil || info.Size != int64(len("precious")) {
+		t.Errorf("the original object was changed: %+v, %v", info, err)
+	}
+}
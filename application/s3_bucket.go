@@ -0,0 +1,206 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// GetBucketTags returns the tags of a bucket. A bucket without tags yields an empty map.
+func (service *s3Service) GetBucketTags(ctx context.Context, bucketName string) (map[string]string, error) {
+	result, err := service.s3Client.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{
+		Bucket: aws.String(bucketName),
+	})
+	tags := map[string]string{}
+	if err != nil {
+		var apiError smithy.APIError
+		if errors.As(err, &apiError) && apiError.ErrorCode() == "NoSuchTagSet" {
+			return tags, nil
+		}
+		log.Printf("Couldn't get tags of bucket %v. Here's why: %v\n", bucketName, err)
+		return nil, err
+	}
+	for _, tag := range result.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+// PutBucketTags replaces the tags of a bucket. An empty map removes all of them.
+func (service *s3Service) PutBucketTags(ctx context.Context, bucketName string, tags map[string]string) error {
+	var err error
+	if len(tags) == 0 {
+		_, err = service.s3Client.DeleteBucketTagging(ctx, &s3.DeleteBucketTaggingInput{
+			Bucket: aws.String(bucketName),
+		})
+	} else {
+		var tagSet []types.Tag
+		for key, value := range tags {
+			tagSet = append(tagSet, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+		_, err = service.s3Client.PutBucketTagging(ctx, &s3.PutBucketTaggingInput{
+			Bucket:  aws.String(bucketName),
+			Tagging: &types.Tagging{TagSet: tagSet},
+		})
+	}
+	if err != nil {
+		log.Printf("Couldn't set tags of bucket %v. Here's why: %v\n", bucketName, err)
+	}
+	return err
+}
+
+// PutBucketLogging turns on server access logging for a bucket, delivering the logs
+// to targetBucket under targetPrefix. The target bucket must exist and must allow the
+// S3 log delivery service to write to it (a bucket policy granting
+// logging.s3.amazonaws.com s3:PutObject), otherwise no logs are ever delivered.
+func (service *s3Service) PutBucketLogging(ctx context.Context, bucketName string, targetBucket string,
+	targetPrefix string) error {
+	exists, err := service.bucketExists(ctx, targetBucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = fmt.Errorf("logging target bucket %v does not exist", targetBucket)
+		log.Printf("Couldn't enable logging on bucket %v. Here's why: %v\n", bucketName, err)
+		return err
+	}
+	log.Printf("Make sure bucket %v grants logging.s3.amazonaws.com permission to write logs, "+
+		"otherwise access logs of %v won't be delivered.\n", targetBucket, bucketName)
+	_, err = service.s3Client.PutBucketLogging(ctx, &s3.PutBucketLoggingInput{
+		Bucket: aws.String(bucketName),
+		BucketLoggingStatus: &types.BucketLoggingStatus{
+			LoggingEnabled: &types.LoggingEnabled{
+				TargetBucket: aws.String(targetBucket),
+				TargetPrefix: aws.String(targetPrefix),
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Couldn't enable logging on bucket %v. Here's why: %v\n", bucketName, err)
+	}
+	return err
+}
+
+// GetBucketLogging returns where the access logs of a bucket are delivered.
+// Both values are empty when logging is disabled.
+func (service *s3Service) GetBucketLogging(ctx context.Context, bucketName string) (string, string, error) {
+	result, err := service.s3Client.GetBucketLogging(ctx, &s3.GetBucketLoggingInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		log.Printf("Couldn't get logging configuration of bucket %v. Here's why: %v\n", bucketName, err)
+		return "", "", err
+	}
+	if result.LoggingEnabled == nil {
+		return "", "", nil
+	}
+	return aws.ToString(result.LoggingEnabled.TargetBucket), aws.ToString(result.LoggingEnabled.TargetPrefix), nil
+}
+
+// IntelligentTieringConfig configures the optional archive tiers of objects stored
+// in the INTELLIGENT_TIERING storage class. A zero day count leaves that tier off.
+type IntelligentTieringConfig struct {
+	ID                    string
+	Prefix                string
+	ArchiveAccessDays     int32
+	DeepArchiveAccessDays int32
+}
+
+var intelligentTieringID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+func (config IntelligentTieringConfig) validate() error {
+	if !intelligentTieringID.MatchString(config.ID) {
+		return fmt.Errorf("intelligent-tiering configuration ID %q must be 1 to 64 letters, digits, '.', '_' or '-'", config.ID)
+	}
+	if config.ArchiveAccessDays == 0 && config.DeepArchiveAccessDays == 0 {
+		return errors.New("intelligent-tiering configuration needs at least one archive tier")
+	}
+	if config.ArchiveAccessDays != 0 && (config.ArchiveAccessDays < 90 || config.ArchiveAccessDays > 730) {
+		return fmt.Errorf("archive access days must be between 90 and 730, got %v", config.ArchiveAccessDays)
+	}
+	if config.DeepArchiveAccessDays != 0 && (config.DeepArchiveAccessDays < 180 || config.DeepArchiveAccessDays > 730) {
+		return fmt.Errorf("deep archive access days must be between 180 and 730, got %v", config.DeepArchiveAccessDays)
+	}
+	if config.ArchiveAccessDays != 0 && config.DeepArchiveAccessDays != 0 &&
+		config.DeepArchiveAccessDays <= config.ArchiveAccessDays {
+		return errors.New("deep archive access days must be greater than archive access days")
+	}
+	return nil
+}
+
+// PutIntelligentTieringConfiguration enables the archive tiers described by config on
+// a bucket. It only affects objects stored with the INTELLIGENT_TIERING storage class.
+func (service *s3Service) PutIntelligentTieringConfiguration(ctx context.Context, bucketName string,
+	config IntelligentTieringConfig) error {
+	if err := config.validate(); err != nil {
+		log.Printf("Couldn't configure intelligent-tiering on bucket %v. Here's why: %v\n", bucketName, err)
+		return err
+	}
+	configuration := &types.IntelligentTieringConfiguration{
+		Id:     aws.String(config.ID),
+		Status: types.IntelligentTieringStatusEnabled,
+	}
+	if config.Prefix != "" {
+		configuration.Filter = &types.IntelligentTieringFilter{Prefix: aws.String(config.Prefix)}
+	}
+	if config.ArchiveAccessDays != 0 {
+		configuration.Tierings = append(configuration.Tierings, types.Tiering{
+			AccessTier: types.IntelligentTieringAccessTierArchiveAccess,
+			Days:       aws.Int32(config.ArchiveAccessDays),
+		})
+	}
+	if config.DeepArchiveAccessDays != 0 {
+		configuration.Tierings = append(configuration.Tierings, types.Tiering{
+			AccessTier: types.IntelligentTieringAccessTierDeepArchiveAccess,
+			Days:       aws.Int32(config.DeepArchiveAccessDays),
+		})
+	}
+	_, err := service.s3Client.PutBucketIntelligentTieringConfiguration(ctx, &s3.PutBucketIntelligentTieringConfigurationInput{
+		Bucket:                          aws.String(bucketName),
+		Id:                              aws.String(config.ID),
+		IntelligentTieringConfiguration: configuration,
+	})
+	if err != nil {
+		log.Printf("Couldn't configure intelligent-tiering on bucket %v. Here's why: %v\n", bucketName, err)
+	}
+	return err
+}
+
+// GetIntelligentTieringConfiguration reads the intelligent-tiering configuration with
+// the given ID from a bucket.
+func (service *s3Service) GetIntelligentTieringConfiguration(ctx context.Context, bucketName string,
+	id string) (*IntelligentTieringConfig, error) {
+	result, err := service.s3Client.GetBucketIntelligentTieringConfiguration(ctx, &s3.GetBucketIntelligentTieringConfigurationInput{
+		Bucket: aws.String(bucketName),
+		Id:     aws.String(id),
+	})
+	if err != nil {
+		log.Printf("Couldn't get intelligent-tiering configuration %v of bucket %v. Here's why: %v\n",
+			id, bucketName, err)
+		return nil, err
+	}
+	config := &IntelligentTieringConfig{ID: id}
+	configuration := result.IntelligentTieringConfiguration
+	if configuration == nil {
+		return config, nil
+	}
+	if configuration.Filter != nil {
+		config.Prefix = aws.ToString(configuration.Filter.Prefix)
+	}
+	for _, tiering := range configuration.Tierings {
+		switch tiering.AccessTier {
+		case types.IntelligentTieringAccessTierArchiveAccess:
+			config.ArchiveAccessDays = aws.ToInt32(tiering.Days)
+		case types.IntelligentTieringAccessTierDeepArchiveAccess:
+			config.DeepArchiveAccessDays = aws.ToInt32(tiering.Days)
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,258 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// copySource builds the URL-encoded CopySource value of a CopyObject request.
+func copySource(bucketName string, objectKey string) string {
+	return (&url.URL{Path: bucketName + "/" + objectKey}).EscapedPath()
+}
+
+// randomKey returns a key next to objectKey that no one else will pick, for objects
+// that only live while a method runs.
+func randomKey(objectKey string, label string) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return objectKey + "." + label + "-" + hex.EncodeToString(suffix), nil
+}
+
+// ErrCopyNotVisible is returned when a copy succeeded server-side but the destination
+// object still wasn't readable when verification gave up.
+var ErrCopyNotVisible = errors.New("copy succeeded but the destination object is not visible yet")
+
+const (
+	defaultCopyVerifyTimeout = 10 * time.Second
+	copyVerifyBackoff        = 100 * time.Millisecond
+)
+
+// verifyCopy waits until the destination of a copy answers HeadObject, when
+// VerifyCopies is set. It retries NotFound with exponential backoff for up to
+// CopyVerifyTimeout and then gives up with ErrCopyNotVisible. customerKey is the SSE-C
+// key of the copy, or nil.
+func (service *s3Service) verifyCopy(ctx context.Context, bucketName string, objectKey string,
+	customerKey *CustomerKey) error {
+	if !service.VerifyCopies {
+		return nil
+	}
+	timeout := service.CopyVerifyTimeout
+	if timeout <= 0 {
+		timeout = defaultCopyVerifyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	delay := copyVerifyBackoff
+	for {
+		input := &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(objectKey),
+		}
+		customerKey.set(&input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
+		_, err := service.s3Client.HeadObject(ctx, input)
+		var notFound *types.NotFound
+		if err == nil || !errors.As(err, &notFound) {
+			return err
+		}
+		if time.Now().Add(delay).After(deadline) {
+			err = fmt.Errorf("%w: %v:%v after %v", ErrCopyNotVisible, bucketName, objectKey, timeout)
+			log.Printf("Couldn't verify copy. Here's why: %v\n", err)
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// CopyObjectWithOptions server-side copies an object, with the copy settings given in
+// options. When VerifyCopies is set, it also waits for the copy to become readable.
+func (service *s3Service) CopyObjectWithOptions(ctx context.Context, srcBucket string, srcKey string,
+	dstBucket string, dstKey string, options CopyOptions) error {
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(dstBucket),
+		CopySource: aws.String(copySource(srcBucket, srcKey)),
+		Key:        aws.String(dstKey),
+	}
+	options.applyTo(input)
+	_, err := service.s3Client.CopyObject(ctx, input)
+	service.invalidateInfo(dstBucket, dstKey)
+	if err != nil {
+		log.Printf("Couldn't copy object from %v:%v to %v:%v. Here's why: %v\n",
+			srcBucket, srcKey, dstBucket, dstKey, err)
+		return err
+	}
+	return service.verifyCopy(ctx, dstBucket, dstKey, options.CustomerKey)
+}
+
+// FlattenCollisionError reports source keys that would be copied onto the same
+// destination key by CopyFlatten, indexed by that destination key.
+type FlattenCollisionError struct {
+	Collisions map[string][]string
+}
+
+func (e *FlattenCollisionError) Error() string {
+	var keys []string
+	for key := range e.Collisions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("%v destination keys would be written by more than one source key: %v",
+		len(keys), strings.Join(keys, ", "))
+}
+
+// CopyFlatten server-side copies every object under srcPrefix into the root of
+// dstBucket, keeping only the part of each key after srcPrefix. srcPrefix is a folder:
+// "logs/2024" copies logs/2024/a as a, and leaves logs/2024-01/b alone. Nothing is
+// copied when two source keys would end up on the same destination key; a
+// *FlattenCollisionError lists them instead.
+func (service *s3Service) CopyFlatten(ctx context.Context, srcBucket string, srcPrefix string,
+	dstBucket string) (int, error) {
+	if srcPrefix != "" && !strings.HasSuffix(srcPrefix, "/") {
+		srcPrefix += "/"
+	}
+	var sources []string
+	targets := map[string][]string{}
+	err := service.walkObjects(ctx, srcBucket, srcPrefix, func(object types.Object) error {
+		key := aws.ToString(object.Key)
+		target := strings.TrimLeft(strings.TrimPrefix(key, srcPrefix), "/")
+		if target == "" {
+			// Folder placeholder for the prefix itself.
+			return nil
+		}
+		sources = append(sources, key)
+		targets[target] = append(targets[target], key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	collisions := map[string][]string{}
+	for target, keys := range targets {
+		if len(keys) > 1 {
+			collisions[target] = keys
+		}
+	}
+	if len(collisions) > 0 {
+		err = &FlattenCollisionError{Collisions: collisions}
+		log.Printf("Couldn't flatten %v:%v into %v. Here's why: %v\n", srcBucket, srcPrefix, dstBucket, err)
+		return 0, err
+	}
+
+	copied := make([]bool, len(sources))
+	err = service.forEach(ctx, 0, len(sources), func(ctx context.Context, i int) error {
+		key := sources[i]
+		target := strings.TrimLeft(strings.TrimPrefix(key, srcPrefix), "/")
+		_, err := service.s3Client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(dstBucket),
+			CopySource: aws.String(copySource(srcBucket, key)),
+			Key:        aws.String(target),
+		})
+		service.invalidateInfo(dstBucket, target)
+		if err != nil {
+			log.Printf("Couldn't copy object from %v:%v to %v:%v. Here's why: %v\n",
+				srcBucket, key, dstBucket, target, err)
+			return err
+		}
+		if err = service.verifyCopy(ctx, dstBucket, target, nil); err != nil {
+			return err
+		}
+		copied[i] = true
+		return nil
+	})
+	count := 0
+	for _, ok := range copied {
+		if ok {
+			count++
+		}
+	}
+	return count, err
+}
+
+// maxCopySize is the largest object a single CopyObject can copy (5 GiB).
+const maxCopySize int64 = 5 * 1024 * 1024 * 1024
+
+// PublishAtomic uploads a file to finalKey so that readers never see it half-written:
+// the file is first uploaded to a temporary key next to it, then copied server-side
+// over finalKey in one step, and the temporary key is deleted whether or not that
+// worked. Readers of finalKey see either the previous object or the complete new one.
+// The content type is detected from the file. Like any CopyObject, the copy step only
+// works for files up to 5 GiB, so larger files fail before anything is uploaded.
+func (service *s3Service) PublishAtomic(ctx context.Context, bucketName string, finalKey string, fileName string) error {
+	tempKey, err := randomKey(finalKey, "tmp")
+	if err != nil {
+		return err
+	}
+	contentType, err := detectContentType(fileName)
+	if err != nil {
+		log.Printf("Couldn't detect the content type of %v. Here's why: %v\n", fileName, err)
+		return err
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		log.Printf("Couldn't open file %v to upload. Here's why: %v\n", fileName, err)
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		log.Printf("Couldn't stat file %v. Here's why: %v\n", fileName, err)
+		return err
+	}
+	if stat.Size() > maxCopySize {
+		err = fmt.Errorf("file %v is %v bytes, more than the %v a CopyObject can publish",
+			fileName, stat.Size(), maxCopySize)
+		log.Printf("Couldn't publish %v to %v:%v. Here's why: %v\n", fileName, bucketName, finalKey, err)
+		return err
+	}
+
+	defer func() {
+		// A canceled ctx must not leave the temporary object behind.
+		_, err := service.s3Client.DeleteObject(context.WithoutCancel(ctx), &s3.DeleteObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(tempKey),
+		})
+		if err != nil {
+			log.Printf("Couldn't delete temporary object %v:%v. Here's why: %v\n", bucketName, tempKey, err)
+		}
+	}()
+	_, err = service.upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(tempKey),
+		Body:        file,
+		ContentType: aws.String(contentType),
+	}, stat.Size())
+	if err != nil {
+		log.Printf("Couldn't upload file %v to %v:%v. Here's why: %v\n", fileName, bucketName, tempKey, err)
+		return err
+	}
+	_, err = service.s3Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		CopySource: aws.String(copySource(bucketName, tempKey)),
+		Key:        aws.String(finalKey),
+	})
+	service.invalidateInfo(bucketName, finalKey)
+	if err != nil {
+		log.Printf("Couldn't publish %v:%v to %v. Here's why: %v\n", bucketName, tempKey, finalKey, err)
+		return err
+	}
+	return service.verifyCopy(ctx, bucketName, finalKey, nil)
+}
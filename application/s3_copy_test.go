@@ -0,0 +1,52 @@
+package application
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestCopyFlattenStopsAtFolders(t *testing.T) {
+	ctx := context.Background()
+	service, fake := newFakeService(t, "src")
+	if _, err := fake.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("dst")}); err != nil {
+		t.Fatal(err)
+	}
+	putFakeObject(t, fake, "src", "logs/2024/a.txt", "a")
+	putFakeObject(t, fake, "src", "logs/2024-01/b.txt", "b")
+
+	copied, err := service.CopyFlatten(ctx, "src", "logs/2024", "dst")
+	if err != nil || copied != 1 {
+		t.Fatalf("CopyFlatten = %v, %v, want 1, nil", copied, err)
+	}
+	if _, err := service.GetObjectInfo(ctx, "dst", "a.txt"); err != nil {
+		t.Errorf("a.txt wasn't copied: %v", err)
+	}
+}
+
+func TestPublishAtomicRejectsFilesTooLargeToCopy(t *testing.T) {
+	service, _ := newFakeService(t, "bucket")
+	fileName := filepath.Join(t.TempDir(), "huge.bin")
+	file, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sparse, so the test doesn't need 5 GiB of disk.
+	if err := file.Truncate(maxCopySize + 1); err != nil {
+		file.Close()
+		t.Skipf("can't create a sparse file: %v", err)
+	}
+	file.Close()
+
+	if err := service.PublishAtomic(context.Background(), "bucket", "huge.bin", fileName); err == nil {
+		t.Fatal("PublishAtomic accepted a file larger than CopyObject can copy")
+	}
+	objects, err := service.ListObjects("bucket")
+	if err != nil || len(objects) != 0 {
+		t.Errorf("ListObjects = %v objects, %v; want nothing uploaded", len(objects), err)
+	}
+}
@@ -0,0 +1,165 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrGovernanceBypassDenied is returned when a delete asked to bypass governance-mode
+// retention and S3 refused it, usually because the caller lacks the
+// s3:BypassGovernanceRetention permission.
+var ErrGovernanceBypassDenied = errors.New("bypassing governance retention was denied")
+
+// firstDeleteOptions returns the options passed to a delete method, if any.
+func firstDeleteOptions(options []DeleteOptions) DeleteOptions {
+	if len(options) > 0 {
+		return options[0]
+	}
+	return DeleteOptions{}
+}
+
+// mapBypassError turns AccessDenied on a delete that bypasses governance retention into
+// ErrGovernanceBypassDenied, keeping the original error in the chain.
+func (options DeleteOptions) mapBypassError(err error) error {
+	if options.BypassGovernanceRetention && isAccessDenied(err) {
+		return fmt.Errorf("%w: %w", ErrGovernanceBypassDenied, err)
+	}
+	return err
+}
+
+// DeleteObjectVersion permanently deletes one version of an object. Versions locked in
+// governance mode can only be deleted with DeleteOptions.BypassGovernanceRetention.
+func (service *s3Service) DeleteObjectVersion(ctx context.Context, bucketName string, objectKey string,
+	versionId string, options ...DeleteOptions) (*DeleteResult, error) {
+	result, err := service.deleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(objectKey),
+		VersionId: aws.String(versionId),
+	}, firstDeleteOptions(options))
+	return result, mapVersionError(err)
+}
+
+// maxDeleteBatch is the most keys a single DeleteObjects request accepts.
+const maxDeleteBatch = 1000
+
+// deleteKeys deletes any number of objects in batches of maxDeleteBatch and returns
+// how many were deleted. Keys S3 refuses to delete are reported in the error.
+func (service *s3Service) deleteKeys(ctx context.Context, bucketName string, objectKeys []string,
+	options DeleteOptions) (int, error) {
+	deleted := 0
+	var errs []error
+	for start := 0; start < len(objectKeys); start += maxDeleteBatch {
+		batch := objectKeys[start:min(start+maxDeleteBatch, len(objectKeys))]
+		var objectIds []types.ObjectIdentifier
+		for _, key := range batch {
+			objectIds = append(objectIds, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+		result, err := service.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket:                    aws.String(bucketName),
+			Delete:                    &types.Delete{Objects: objectIds, Quiet: aws.Bool(true)},
+			BypassGovernanceRetention: options.bypass(),
+		})
+		for _, key := range batch {
+			service.invalidateInfo(bucketName, key)
+		}
+		if err != nil {
+			err = options.mapBypassError(err)
+			log.Printf("Couldn't delete objects from bucket %v. Here's why: %v\n", bucketName, err)
+			return deleted, errors.Join(append(errs, err)...)
+		}
+		deleted += len(batch) - len(result.Errors)
+		for _, failure := range result.Errors {
+			err := fmt.Errorf("couldn't delete %v:%v: %v",
+				bucketName, aws.ToString(failure.Key), aws.ToString(failure.Message))
+			if options.BypassGovernanceRetention && aws.ToString(failure.Code) == "AccessDenied" {
+				err = fmt.Errorf("%w: %w", ErrGovernanceBypassDenied, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// DeleteOlderThan deletes every object under a prefix last modified more than age ago
+// and returns how many were deleted. With dryRun set, nothing is deleted and the
+// count is the number of objects that would be. The optional DeleteOptions apply to
+// every delete.
+func (service *s3Service) DeleteOlderThan(ctx context.Context, bucketName string, prefix string,
+	age time.Duration, dryRun bool, options ...DeleteOptions) (int, error) {
+	cutoff := time.Now().Add(-age)
+	var keys []string
+	err := service.walkObjects(ctx, bucketName, prefix, func(object types.Object) error {
+		if aws.ToTime(object.LastModified).Before(cutoff) {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if dryRun {
+		for _, key := range keys {
+			log.Printf("Would delete %v:%v.\n", bucketName, key)
+		}
+		return len(keys), nil
+	}
+	return service.deleteKeys(ctx, bucketName, keys, firstDeleteOptions(options))
+}
+
+// DeleteExpired deletes every object under a prefix whose Expires header is in the
+// past and returns how many were deleted. Listings don't include headers, so each
+// object is read with GetObjectInfo, concurrently. Objects without an Expires header
+// are kept. This only looks at the header; lifecycle expiration rules are unrelated.
+// With dryRun set, nothing is deleted and the count is the number of objects that
+// would be. Objects that couldn't be read are reported in the error. The optional
+// DeleteOptions apply to every delete.
+func (service *s3Service) DeleteExpired(ctx context.Context, bucketName string, prefix string,
+	dryRun bool, options ...DeleteOptions) (int, error) {
+	var keys []string
+	err := service.walkObjects(ctx, bucketName, prefix, func(object types.Object) error {
+		keys = append(keys, aws.ToString(object.Key))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var mutex sync.Mutex
+	var expired []string
+	headErr := service.forEach(ctx, 0, len(keys), func(ctx context.Context, i int) error {
+		info, err := service.GetObjectInfo(ctx, bucketName, keys[i])
+		var notFound *types.NotFound
+		switch {
+		case errors.As(err, &notFound):
+			return nil
+		case err != nil:
+			return fmt.Errorf("%v: %w", keys[i], err)
+		}
+		if !info.Expires.IsZero() && info.Expires.Before(now) {
+			mutex.Lock()
+			expired = append(expired, keys[i])
+			mutex.Unlock()
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		return 0, headErr
+	}
+	if dryRun {
+		for _, key := range expired {
+			log.Printf("Would delete %v:%v.\n", bucketName, key)
+		}
+		return len(expired), headErr
+	}
+	deleted, err := service.deleteKeys(ctx, bucketName, expired, firstDeleteOptions(options))
+	return deleted, errors.Join(headErr, err)
+}
@@ -0,0 +1,90 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestBypassGovernanceRetentionIsPerCall(t *testing.T) {
+	ctx := context.Background()
+	var headers []http.Header
+	service := newStubService(headerTransport{headers: &headers})
+	if _, err := service.DeleteObjectVersion(ctx, "bucket", "key", "v1",
+		DeleteOptions{BypassGovernanceRetention: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.DeleteObjectVersion(ctx, "bucket", "key", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 2 {
+		t.Fatalf("got %v requests, want 2", len(headers))
+	}
+	if got := headers[0].Get("X-Amz-Bypass-Governance-Retention"); got != "true" {
+		t.Errorf("bypassing delete sent x-amz-bypass-governance-retention %q, want true", got)
+	}
+	if got := headers[1].Get("X-Amz-Bypass-Governance-Retention"); got != "" {
+		t.Errorf("plain delete sent x-amz-bypass-governance-retention %q", got)
+	}
+}
+
+func TestBypassGovernanceRetentionDenied(t *testing.T) {
+	ctx := context.Background()
+	service := newStubService(stubTransport{status: 403, body: `<Error><Code>AccessDenied</Code>` +
+		`<Message>Access Denied</Message></Error>`})
+	_, err := service.DeleteObject(ctx, "bucket", "key", DeleteOptions{BypassGovernanceRetention: true})
+	if !errors.Is(err, ErrGovernanceBypassDenied) {
+		t.Errorf("bypassing delete: error = %v, want ErrGovernanceBypassDenied", err)
+	}
+	_, err = service.DeleteObject(ctx, "bucket", "key")
+	if err == nil || errors.Is(err, ErrGovernanceBypassDenied) {
+		t.Errorf("plain delete: error = %v, want a plain AccessDenied", err)
+	}
+}
+
+// bypassRecorder records whether each DeleteObjects request bypassed governance
+// retention.
+type bypassRecorder struct {
+	*FakeS3
+	bypass *[]bool
+}
+
+func (fake bypassRecorder) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput,
+	optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	*fake.bypass = append(*fake.bypass, aws.ToBool(params.BypassGovernanceRetention))
+	return fake.FakeS3.DeleteObjects(ctx, params, optFns...)
+}
+
+func TestCleanupMethodsPassDeleteOptions(t *testing.T) {
+	ctx := context.Background()
+	_, fake := newFakeService(t, "bucket")
+	var bypass []bool
+	service := NewS3ServiceWithClient(bypassRecorder{FakeS3: fake, bypass: &bypass})
+	putFakeObject(t, fake, "bucket", "old/a", "a")
+	_, err := fake.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:  aws.String("bucket"),
+		Key:     aws.String("expired/b"),
+		Body:    strings.NewReader("b"),
+		Expires: aws.Time(time.Now().Add(-time.Hour)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := DeleteOptions{BypassGovernanceRetention: true}
+	if deleted, err := service.DeleteOlderThan(ctx, "bucket", "old/", -time.Hour, false, options); err != nil || deleted != 1 {
+		t.Fatalf("DeleteOlderThan = %v, %v, want 1, nil", deleted, err)
+	}
+	if deleted, err := service.DeleteExpired(ctx, "bucket", "expired/", false, options); err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpired = %v, %v, want 1, nil", deleted, err)
+	}
+	if len(bypass) != 2 || !bypass[0] || !bypass[1] {
+		t.Errorf("DeleteObjects bypassed governance retention %v, want [true true]", bypass)
+	}
+}
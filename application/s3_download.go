@@ -0,0 +1,366 @@
+package application
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// ErrNoSuchVersion is returned when the requested version of an object doesn't exist.
+var ErrNoSuchVersion = errors.New("no such object version")
+
+// mapVersionError turns the NoSuchVersion API error into ErrNoSuchVersion, keeping
+// the original error in the chain.
+func mapVersionError(err error) error {
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) && apiError.ErrorCode() == "NoSuchVersion" {
+		return fmt.Errorf("%w: %w", ErrNoSuchVersion, err)
+	}
+	return err
+}
+
+// DownloadVersion stores a specific version of an object in a local file, with the
+// same retry and cleanup behavior as DownloadFile.
+func (service *s3Service) DownloadVersion(ctx context.Context, bucketName string, objectKey string,
+	versionId string, fileName string) error {
+	err := withRetry(ctx, service.downloadAttempts(), service.downloadBackoff(), isPartialRead, func() error {
+		return service.downloadOnce(ctx, &s3.GetObjectInput{
+			Bucket:    aws.String(bucketName),
+			Key:       aws.String(objectKey),
+			VersionId: aws.String(versionId),
+		}, fileName)
+	})
+	return mapVersionError(err)
+}
+
+// OpenObject opens the body of an object for streaming. The caller must close it.
+func (service *s3Service) OpenObject(ctx context.Context, bucketName string, objectKey string) (io.ReadCloser, error) {
+	return service.OpenObjectWithOptions(ctx, bucketName, objectKey, DownloadOptions{})
+}
+
+// OpenObjectWithOptions opens the body of an object like OpenObject, with the read
+// settings given in options.
+func (service *s3Service) OpenObjectWithOptions(ctx context.Context, bucketName string, objectKey string,
+	options DownloadOptions) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}
+	options.applyTo(input)
+	return service.openObject(ctx, input)
+}
+
+// OpenObjectVersion opens the body of a specific version of an object for streaming.
+// The caller must close it.
+func (service *s3Service) OpenObjectVersion(ctx context.Context, bucketName string, objectKey string,
+	versionId string) (io.ReadCloser, error) {
+	body, err := service.openObject(ctx, &s3.GetObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(objectKey),
+		VersionId: aws.String(versionId),
+	})
+	return body, mapVersionError(err)
+}
+
+func (service *s3Service) openObject(ctx context.Context, input *s3.GetObjectInput) (io.ReadCloser, error) {
+	result, err := service.s3Client.GetObject(ctx, input)
+	if err != nil {
+		log.Printf("Couldn't get object %v:%v. Here's why: %v\n",
+			aws.ToString(input.Bucket), aws.ToString(input.Key), err)
+		return nil, err
+	}
+	return result.Body, nil
+}
+
+type rangeResult struct {
+	data []byte
+	err  error
+}
+
+// DownloadSequential downloads an object in ranges of the part size and writes them
+// to w strictly in order, for writers that can't take the random-access writes of the
+// download manager (pipes, hashes). Up to SequentialPrefetch ranges are fetched
+// ahead concurrently, so at most that many parts are held in memory at once.
+func (service *s3Service) DownloadSequential(ctx context.Context, bucketName string, objectKey string,
+	w io.Writer) (int64, error) {
+	head, err := service.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		log.Printf("Couldn't get info of object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return 0, err
+	}
+	size := aws.ToInt64(head.ContentLength)
+	parts := int((size + defaultPartSize - 1) / defaultPartSize)
+	prefetch := service.clampConcurrency(ctx, service.SequentialPrefetch)
+	release, err := service.acquire(ctx, prefetch)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chan rangeResult, parts)
+	for i := range results {
+		results[i] = make(chan rangeResult, 1)
+	}
+	window := make(chan struct{}, prefetch)
+	go func() {
+		for i := 0; i < parts; i++ {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int) {
+				start := int64(i) * defaultPartSize
+				end := min(start+defaultPartSize, size) - 1
+				result, err := service.s3Client.GetObject(ctx, &s3.GetObjectInput{
+					Bucket:  aws.String(bucketName),
+					Key:     aws.String(objectKey),
+					Range:   aws.String(fmt.Sprintf("bytes=%v-%v", start, end)),
+					IfMatch: head.ETag,
+				})
+				if err != nil {
+					results[i] <- rangeResult{err: err}
+					return
+				}
+				defer result.Body.Close()
+				data, err := io.ReadAll(result.Body)
+				results[i] <- rangeResult{data: data, err: err}
+			}(i)
+		}
+	}()
+
+	var written int64
+	for i := 0; i < parts; i++ {
+		var result rangeResult
+		select {
+		case result = <-results[i]:
+		case <-ctx.Done():
+			return written, ctx.Err()
+		}
+		<-window
+		if result.err != nil {
+			log.Printf("Couldn't download part %v of %v:%v. Here's why: %v\n", i, bucketName, objectKey, result.err)
+			return written, result.err
+		}
+		n, err := w.Write(result.data)
+		written += int64(n)
+		if err != nil {
+			log.Printf("Couldn't write part %v of %v:%v. Here's why: %v\n", i, bucketName, objectKey, err)
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// ErrChecksumMismatch is returned when a downloaded body doesn't hash to the expected
+// SHA-256 digest.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// objectSHA256 reads the SHA-256 checksum S3 stored for an object, as a hex digest,
+// together with the ETag it belongs to. Only objects uploaded with a SHA-256 checksum
+// have one, and for multipart uploads it is a checksum of the part checksums that
+// can't be compared with a hash of the whole body. Nothing in the checksum itself
+// tells the two apart, so objects made of parts are refused.
+func (service *s3Service) objectSHA256(ctx context.Context, bucketName string, objectKey string) (string, string, error) {
+	result, err := service.s3Client.GetObjectAttributes(ctx, &s3.GetObjectAttributesInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+		ObjectAttributes: []types.ObjectAttributes{
+			types.ObjectAttributesChecksum, types.ObjectAttributesEtag, types.ObjectAttributesObjectParts,
+		},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if result.Checksum == nil || result.Checksum.ChecksumSHA256 == nil {
+		return "", "", fmt.Errorf("object %v:%v has no SHA-256 checksum", bucketName, objectKey)
+	}
+	if result.ObjectParts != nil && aws.ToInt32(result.ObjectParts.TotalPartsCount) > 0 {
+		return "", "", fmt.Errorf("object %v:%v was uploaded in %v parts and only has a checksum of their checksums",
+			bucketName, objectKey, aws.ToInt32(result.ObjectParts.TotalPartsCount))
+	}
+	checksum := aws.ToString(result.Checksum.ChecksumSHA256)
+	digest, err := base64.StdEncoding.DecodeString(checksum)
+	if err != nil {
+		return "", "", fmt.Errorf("object %v:%v has a malformed checksum %q: %w", bucketName, objectKey, checksum, err)
+	}
+	return hex.EncodeToString(digest), aws.ToString(result.ETag), nil
+}
+
+// DownloadVerified stores an object in a local file while hashing it in the same pass,
+// and removes the file and returns ErrChecksumMismatch when the SHA-256 digest differs
+// from expectedSHA256 (hex encoded). When expectedSHA256 is empty, the checksum S3
+// stored for the object is used, and the download is pinned to the ETag it was read
+// with.
+func (service *s3Service) DownloadVerified(ctx context.Context, bucketName string, objectKey string,
+	fileName string, expectedSHA256 string) (err error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}
+	if expectedSHA256 == "" {
+		var etag string
+		expectedSHA256, etag, err = service.objectSHA256(ctx, bucketName, objectKey)
+		if err != nil {
+			log.Printf("Couldn't get the checksum of %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+			return err
+		}
+		if etag != "" {
+			input.IfMatch = aws.String(`"` + strings.Trim(etag, `"`) + `"`)
+		}
+	}
+	result, err := service.s3Client.GetObject(ctx, input)
+	if err != nil {
+		log.Printf("Couldn't get object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return err
+	}
+	defer result.Body.Close()
+	file, err := os.Create(fileName)
+	if err != nil {
+		log.Printf("Couldn't create file %v. Here's why: %v\n", fileName, err)
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(fileName)
+		}
+	}()
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(file, hash), result.Body); err != nil {
+		log.Printf("Couldn't read object body from %v. Here's why: %v\n", objectKey, err)
+		return err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, expectedSHA256) {
+		err = fmt.Errorf("%w: %v:%v hashed to %v, expected %v", ErrChecksumMismatch, bucketName, objectKey,
+			actual, expectedSHA256)
+		log.Printf("Couldn't verify download of %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return err
+	}
+	return nil
+}
+
+// ParallelDownloadToFile downloads an object into a local file in parts ranges fetched
+// concurrently, each written straight to its offset of the file, so the object is never
+// held in memory. The file is created at the object size up front. Objects whose
+// endpoint doesn't advertise range support are downloaded with a single GET instead.
+// Every request is pinned to the ETag seen by HeadObject, and the file is removed when
+// any part fails. It returns the number of bytes downloaded.
+func (service *s3Service) ParallelDownloadToFile(ctx context.Context, bucketName string, objectKey string,
+	fileName string, parts int) (written int64, err error) {
+	head, err := service.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		log.Printf("Couldn't get info of object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return 0, err
+	}
+	size := aws.ToInt64(head.ContentLength)
+	file, err := os.Create(fileName)
+	if err != nil {
+		log.Printf("Couldn't create file %v. Here's why: %v\n", fileName, err)
+		return 0, err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(fileName)
+			written = 0
+		}
+	}()
+	if err = file.Truncate(size); err != nil {
+		log.Printf("Couldn't allocate %v bytes for file %v. Here's why: %v\n", size, fileName, err)
+		return 0, err
+	}
+
+	// getRange writes bytes [start, end] of the object to the file, or the whole object
+	// when end is negative.
+	getRange := func(ctx context.Context, start int64, end int64) error {
+		input := &s3.GetObjectInput{
+			Bucket:  aws.String(bucketName),
+			Key:     aws.String(objectKey),
+			IfMatch: head.ETag,
+		}
+		length := size - start
+		if end >= 0 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%v-%v", start, end))
+			length = end - start + 1
+		}
+		result, err := service.s3Client.GetObject(ctx, input)
+		if err != nil {
+			return err
+		}
+		defer result.Body.Close()
+		copied, err := io.Copy(io.NewOffsetWriter(file, start), result.Body)
+		if err == nil && copied != length {
+			err = fmt.Errorf("got %v bytes at offset %v, expected %v", copied, start, length)
+		}
+		return err
+	}
+
+	if aws.ToString(head.AcceptRanges) != "bytes" || size == 0 {
+		err = getRange(ctx, 0, -1)
+	} else {
+		if parts <= 0 {
+			parts = defaultConcurrency
+		}
+		partSize := (size + int64(parts) - 1) / int64(parts)
+		count := int((size + partSize - 1) / partSize)
+		err = service.forEach(ctx, count, count, func(ctx context.Context, i int) error {
+			start := int64(i) * partSize
+			return getRange(ctx, start, min(start+partSize, size)-1)
+		})
+	}
+	if err != nil {
+		log.Printf("Couldn't download object %v:%v to file %v. Here's why: %v\n", bucketName, objectKey, fileName, err)
+		return 0, err
+	}
+	return size, nil
+}
+
+// ProcessObjects streams every object under a prefix whose key matches keyRegex (all
+// of them when keyRegex is nil) into fn, one at a time in key order, without storing
+// them anywhere. Each body is closed once fn returns. It stops at the first error,
+// wrapped with the key it happened on.
+func (service *s3Service) ProcessObjects(ctx context.Context, bucketName string, prefix string,
+	keyRegex *regexp.Regexp, fn func(key string, r io.Reader) error) error {
+	return service.walkObjects(ctx, bucketName, prefix, func(object types.Object) error {
+		key := aws.ToString(object.Key)
+		if keyRegex != nil && !keyRegex.MatchString(key) {
+			return nil
+		}
+		body, err := service.OpenObject(ctx, bucketName, key)
+		if err != nil {
+			return fmt.Errorf("%v: %w", key, err)
+		}
+		defer body.Close()
+		if err := fn(key, body); err != nil {
+			return fmt.Errorf("%v: %w", key, err)
+		}
+		return nil
+	})
+}
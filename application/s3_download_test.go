@@ -0,0 +1,92 @@
+package application
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestDownloadVerified(t *testing.T) {
+	ctx := context.Background()
+	service, fake := newFakeService(t, "bucket")
+	_, err := fake.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:            aws.String("bucket"),
+		Key:               aws.String("key"),
+		Body:              strings.NewReader("hello"),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "key")
+	sum := sha256.Sum256([]byte("hello"))
+
+	if err := service.DownloadVerified(ctx, "bucket", "key", fileName, ""); err != nil {
+		t.Errorf("with the stored checksum: %v", err)
+	}
+	if err := service.DownloadVerified(ctx, "bucket", "key", fileName, hex.EncodeToString(sum[:])); err != nil {
+		t.Errorf("with the expected digest: %v", err)
+	}
+	err = service.DownloadVerified(ctx, "bucket", "key", fileName, strings.Repeat("0", 64))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("with a wrong digest: error = %v, want ErrChecksumMismatch", err)
+	}
+	if _, err := os.Stat(fileName); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the file of a mismatched download was kept")
+	}
+}
+
+func TestDownloadVerifiedRefusesMultipartChecksums(t *testing.T) {
+	ctx := context.Background()
+	service, fake := newFakeService(t, "bucket")
+	upload, err := fake.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String("bucket"),
+		Key:               aws.String("key"),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []types.CompletedPart
+	for i, data := range []string{"hello ", "world"} {
+		number := aws.Int32(int32(i + 1))
+		_, err := fake.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String("bucket"),
+			Key:        aws.String("key"),
+			UploadId:   upload.UploadId,
+			PartNumber: number,
+			Body:       strings.NewReader(data),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, types.CompletedPart{PartNumber: number})
+	}
+	_, err = fake.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String("bucket"),
+		Key:             aws.String("key"),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "key")
+	err = service.DownloadVerified(ctx, "bucket", "key", fileName, "")
+	if err == nil || errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("error = %v, want a refusal to compare a checksum of parts", err)
+	}
+	if _, err := os.Stat(fileName); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a file was written although nothing could be verified")
+	}
+}
@@ -0,0 +1,88 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// kmsKeyMatches reports whether the key ARN S3 reports for an object is the key that
+// was requested by ID or ARN. Aliases can't be resolved without KMS, so they always
+// match.
+func kmsKeyMatches(reported string, requested string) bool {
+	if strings.HasPrefix(requested, "alias/") || strings.Contains(requested, ":alias/") {
+		return true
+	}
+	return reported == requested || strings.HasSuffix(reported, ":key/"+requested)
+}
+
+// ReencryptObject rewrites an object in place under a new KMS key, keeping its body,
+// metadata and storage class, and then checks with GetObjectInfo that S3 reports the
+// new key.
+func (service *s3Service) ReencryptObject(ctx context.Context, bucketName string, objectKey string,
+	newKmsKeyID string) error {
+	info, err := service.GetObjectInfo(ctx, bucketName, objectKey)
+	if err != nil {
+		return err
+	}
+	input := &s3.CopyObjectInput{
+		Bucket:               aws.String(bucketName),
+		CopySource:           aws.String(copySource(bucketName, objectKey)),
+		Key:                  aws.String(objectKey),
+		MetadataDirective:    types.MetadataDirectiveCopy,
+		ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+		SSEKMSKeyId:          aws.String(newKmsKeyID),
+	}
+	if info.StorageClass != "" {
+		input.StorageClass = info.StorageClass
+	}
+	_, err = service.s3Client.CopyObject(ctx, input)
+	service.invalidateInfo(bucketName, objectKey)
+	if err != nil {
+		log.Printf("Couldn't re-encrypt object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return err
+	}
+
+	info, err = service.GetObjectInfo(ctx, bucketName, objectKey)
+	if err != nil {
+		return err
+	}
+	if info.ServerSideEncryption != types.ServerSideEncryptionAwsKms || !kmsKeyMatches(info.SSEKMSKeyId, newKmsKeyID) {
+		err = fmt.Errorf("object %v:%v reports encryption %v with key %q after re-encrypting with %q",
+			bucketName, objectKey, info.ServerSideEncryption, info.SSEKMSKeyId, newKmsKeyID)
+		log.Printf("Couldn't verify re-encryption. Here's why: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// ReencryptPrefix re-encrypts every object under a prefix with ReencryptObject,
+// concurrently, and returns how many succeeded. The error joins the failures of
+// individual objects.
+func (service *s3Service) ReencryptPrefix(ctx context.Context, bucketName string, prefix string,
+	newKmsKeyID string) (int, error) {
+	var keys []string
+	err := service.walkObjects(ctx, bucketName, prefix, func(object types.Object) error {
+		keys = append(keys, aws.ToString(object.Key))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var reencrypted atomic.Int64
+	err = service.forEach(ctx, 0, len(keys), func(ctx context.Context, i int) error {
+		if err := service.ReencryptObject(ctx, bucketName, keys[i], newKmsKeyID); err != nil {
+			return fmt.Errorf("%v: %w", keys[i], err)
+		}
+		reencrypted.Add(1)
+		return nil
+	})
+	return int(reencrypted.Load()), err
+}
@@ -0,0 +1,131 @@
+package application
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is the compression applied to an exported archive.
+type Codec int
+
+const (
+	CodecGzip Codec = iota
+	CodecNone
+	CodecZstd
+)
+
+// ExportOptions configures ExportPrefix. The zero value writes a .tar.gz at the
+// default gzip level.
+type ExportOptions struct {
+	Codec Codec
+	// Level is the compression level: 1 (fastest) to 9 for gzip, 1 to 22 for zstd,
+	// with zstd levels mapped to the nearest level klauspost/compress implements.
+	// Zero means the default level of the codec; it must be zero for CodecNone.
+	Level int
+}
+
+// compressor wraps w in the writer of the codec selected by options.
+func (options ExportOptions) compressor(w io.Writer) (io.WriteCloser, error) {
+	switch options.Codec {
+	case CodecNone:
+		if options.Level != 0 {
+			return nil, fmt.Errorf("compression level %v set without a codec", options.Level)
+		}
+		return nopWriteCloser{w}, nil
+	case CodecGzip:
+		if options.Level == 0 {
+			return gzip.NewWriter(w), nil
+		}
+		if options.Level < gzip.BestSpeed || options.Level > gzip.BestCompression {
+			return nil, fmt.Errorf("gzip level must be between %v and %v, got %v",
+				gzip.BestSpeed, gzip.BestCompression, options.Level)
+		}
+		return gzip.NewWriterLevel(w, options.Level)
+	case CodecZstd:
+		if options.Level == 0 {
+			return zstd.NewWriter(w)
+		}
+		if options.Level < 1 || options.Level > 22 {
+			return nil, fmt.Errorf("zstd level must be between 1 and 22, got %v", options.Level)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(options.Level)))
+	}
+	return nil, fmt.Errorf("unknown codec %v", options.Codec)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// ExportPrefix writes every object under a prefix to w as a tar archive, compressed
+// as options select, streaming the objects one after the other so neither they nor
+// the archive are held in memory. Entries are named by the key relative to the
+// prefix without leading slashes; zero-byte folder markers are left out, and the
+// export fails on keys whose names would be absolute or climb out of the archive
+// root with "..". It returns how many objects were exported.
+func (service *s3Service) ExportPrefix(ctx context.Context, bucketName string, prefix string, w io.Writer,
+	options ExportOptions) (int, error) {
+	compressed, err := options.compressor(w)
+	if err != nil {
+		log.Printf("Couldn't export %v:%v. Here's why: %v\n", bucketName, prefix, err)
+		return 0, err
+	}
+	archive := tar.NewWriter(compressed)
+	exported := 0
+	err = service.walkObjects(ctx, bucketName, prefix, func(object types.Object) error {
+		key := aws.ToString(object.Key)
+		name := strings.TrimLeft(strings.TrimPrefix(key, prefix), "/")
+		size := aws.ToInt64(object.Size)
+		if name == "" || (strings.HasSuffix(name, "/") && size == 0) {
+			return nil
+		}
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("%v: key escapes the archive root", key)
+		}
+		err := archive.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     size,
+			Mode:     0644,
+			ModTime:  aws.ToTime(object.LastModified),
+		})
+		if err != nil {
+			return fmt.Errorf("%v: %w", key, err)
+		}
+		body, err := service.OpenObject(ctx, bucketName, key)
+		if err != nil {
+			return fmt.Errorf("%v: %w", key, err)
+		}
+		defer body.Close()
+		if _, err = io.CopyN(archive, body, size); err != nil {
+			return fmt.Errorf("%v: %w", key, err)
+		}
+		exported++
+		return nil
+	})
+	if err == nil {
+		err = archive.Close()
+	}
+	if closeErr := compressed.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("Couldn't export %v:%v. Here's why: %v\n", bucketName, prefix, err)
+		return exported, err
+	}
+	return exported, nil
+}
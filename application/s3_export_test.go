@@ -0,0 +1,43 @@
+package application
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestExportPrefixNames(t *testing.T) {
+	ctx := context.Background()
+	service, fake := newFakeService(t, "bucket")
+	putFakeObject(t, fake, "bucket", "logs/a.txt", "a")
+	putFakeObject(t, fake, "bucket", "logs//b.txt", "b")
+
+	var archive bytes.Buffer
+	exported, err := service.ExportPrefix(ctx, "bucket", "logs", &archive, ExportOptions{Codec: CodecNone})
+	if err != nil || exported != 2 {
+		t.Fatalf("ExportPrefix = %v, %v, want 2, nil", exported, err)
+	}
+	var names []string
+	reader := tar.NewReader(&archive)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	if want := []string{"b.txt", "a.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("entries = %q, want %q", names, want)
+	}
+
+	putFakeObject(t, fake, "bucket", "logs/../../etc/x", "x")
+	if _, err := service.ExportPrefix(ctx, "bucket", "logs", io.Discard, ExportOptions{Codec: CodecNone}); err == nil {
+		t.Errorf("exporting a key that climbs out of the archive root succeeded")
+	}
+}
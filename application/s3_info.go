@@ -0,0 +1,130 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ObjectInfo is the metadata of an object as reported by HeadObject.
+type ObjectInfo struct {
+	Key             string
+	Size            int64
+	ETag            string
+	LastModified    time.Time
+	ContentType     string
+	ContentLanguage string
+	ContentEncoding string
+	CacheControl    string
+	// ContentDisposition is empty unless it was set when the object was written.
+	ContentDisposition string
+	// Expires is the zero time when the object has no (valid) Expires header.
+	Expires  time.Time
+	Metadata map[string]string
+
+	StorageClass         types.StorageClass
+	ServerSideEncryption types.ServerSideEncryption
+	SSEKMSKeyId          string
+}
+
+// GetObjectInfo reads the metadata of an object without downloading its body.
+// Results are served from the in-memory cache when InfoCacheSize is set.
+func (service *s3Service) GetObjectInfo(ctx context.Context, bucketName string, objectKey string) (*ObjectInfo, error) {
+	return service.GetObjectInfoWithOptions(ctx, bucketName, objectKey, DownloadOptions{})
+}
+
+// GetObjectInfoWithOptions reads the metadata of an object like GetObjectInfo, with
+// the read settings given in options. Lookups with a CustomerKey bypass the cache, so
+// the metadata of SSE-C objects is never served to callers without the key.
+func (service *s3Service) GetObjectInfoWithOptions(ctx context.Context, bucketName string, objectKey string,
+	options DownloadOptions) (*ObjectInfo, error) {
+	cache := service.cache()
+	if options.CustomerKey != nil {
+		cache = nil
+	}
+	cacheKey := infoCacheKey(bucketName, objectKey)
+	var generation uint64
+	if cache != nil {
+		if info, found := cache.get(cacheKey); found {
+			return &info, nil
+		}
+		generation = cache.begin(cacheKey)
+	}
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}
+	options.CustomerKey.set(&input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
+	result, err := service.s3Client.HeadObject(ctx, input)
+	if err != nil {
+		if cache != nil {
+			cache.finish(cacheKey, generation, nil)
+		}
+		log.Printf("Couldn't get info of object %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return nil, err
+	}
+	info := ObjectInfo{
+		Key:                objectKey,
+		Size:               aws.ToInt64(result.ContentLength),
+		ETag:               aws.ToString(result.ETag),
+		LastModified:       aws.ToTime(result.LastModified),
+		ContentType:        aws.ToString(result.ContentType),
+		ContentLanguage:    aws.ToString(result.ContentLanguage),
+		ContentEncoding:    aws.ToString(result.ContentEncoding),
+		CacheControl:       aws.ToString(result.CacheControl),
+		ContentDisposition: aws.ToString(result.ContentDisposition),
+		Expires:            aws.ToTime(result.Expires),
+		Metadata:           result.Metadata,
+
+		StorageClass:         result.StorageClass,
+		ServerSideEncryption: result.ServerSideEncryption,
+		SSEKMSKeyId:          aws.ToString(result.SSEKMSKeyId),
+	}
+	if cache != nil {
+		cache.finish(cacheKey, generation, &info)
+	}
+	return &info, nil
+}
+
+// ObjectsExist checks concurrently which of keys exist in a bucket, with one HeadObject
+// per key. Both lists keep the order of keys. Keys that couldn't be checked are in
+// neither list; their failures are joined in the error.
+func (service *s3Service) ObjectsExist(ctx context.Context, bucketName string, keys []string) ([]string, []string, error) {
+	exists := make([]bool, len(keys))
+	checked := make([]bool, len(keys))
+	err := service.forEach(ctx, 0, len(keys), func(ctx context.Context, i int) error {
+		_, err := service.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(keys[i]),
+		})
+		var notFound *types.NotFound
+		switch {
+		case err == nil:
+			exists[i] = true
+		case !errors.As(err, &notFound):
+			return fmt.Errorf("%v: %w", keys[i], err)
+		}
+		checked[i] = true
+		return nil
+	})
+	var present, missing []string
+	for i, key := range keys {
+		switch {
+		case !checked[i]:
+		case exists[i]:
+			present = append(present, key)
+		default:
+			missing = append(missing, key)
+		}
+	}
+	if err != nil {
+		log.Printf("Couldn't check every object in bucket %v. Here's why: %v\n", bucketName, err)
+	}
+	return present, missing, err
+}
@@ -0,0 +1,276 @@
+package application
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrNoObjects is returned when a prefix holds no objects at all.
+var ErrNoObjects = errors.New("no objects found")
+
+// SortField selects how listed objects are ordered.
+type SortField int
+
+const (
+	// SortNone keeps the order returned by the API.
+	SortNone SortField = iota
+	SortByKey
+	SortBySize
+	SortByLastModified
+)
+
+// ListOptions narrows and orders the results of ListObjectsWithOptions.
+type ListOptions struct {
+	Prefix     string
+	SortBy     SortField
+	Descending bool
+}
+
+// walkObjects pages through every object under a prefix, calling fn for each one.
+// It stops at the first error returned by fn.
+func (service *s3Service) walkObjects(ctx context.Context, bucketName string, prefix string,
+	fn func(object types.Object) error) error {
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(bucketName)}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	paginator := s3.NewListObjectsV2Paginator(service.s3Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Couldn't list objects in bucket %v. Here's why: %v\n", bucketName, err)
+			return err
+		}
+		for _, object := range page.Contents {
+			if err = fn(object); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ListObjectsWithOptions lists every object under options.Prefix, following
+// pagination, and sorts the result as requested.
+func (service *s3Service) ListObjectsWithOptions(ctx context.Context, bucketName string,
+	options ListOptions) ([]types.Object, error) {
+	var objects []types.Object
+	err := service.walkObjects(ctx, bucketName, options.Prefix, func(object types.Object) error {
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortObjects(objects, options.SortBy, options.Descending)
+	return objects, nil
+}
+
+func sortObjects(objects []types.Object, sortBy SortField, descending bool) {
+	var less func(a, b types.Object) bool
+	switch sortBy {
+	case SortByKey:
+		less = func(a, b types.Object) bool { return aws.ToString(a.Key) < aws.ToString(b.Key) }
+	case SortBySize:
+		less = func(a, b types.Object) bool { return aws.ToInt64(a.Size) < aws.ToInt64(b.Size) }
+	case SortByLastModified:
+		less = func(a, b types.Object) bool { return aws.ToTime(a.LastModified).Before(aws.ToTime(b.LastModified)) }
+	default:
+		return
+	}
+	sort.SliceStable(objects, func(i, j int) bool {
+		if descending {
+			return less(objects[j], objects[i])
+		}
+		return less(objects[i], objects[j])
+	})
+}
+
+// PrefixDigest returns a hex SHA-256 digest of the key, size and ETag of every object
+// under a prefix. The digest stays the same as long as no object under the prefix is
+// added, removed or modified, so it can be compared to skip reprocessing.
+func (service *s3Service) PrefixDigest(ctx context.Context, bucketName string, prefix string) (string, error) {
+	objects, err := service.ListObjectsWithOptions(ctx, bucketName, ListOptions{Prefix: prefix, SortBy: SortByKey})
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	for _, object := range objects {
+		fmt.Fprintf(hash, "%v\x00%v\x00%v\n", aws.ToString(object.Key), aws.ToInt64(object.Size), aws.ToString(object.ETag))
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// LatestObject returns the most recently modified object under a prefix, or
+// ErrNoObjects when the prefix is empty.
+func (service *s3Service) LatestObject(ctx context.Context, bucketName string, prefix string) (*types.Object, error) {
+	var latest *types.Object
+	err := service.walkObjects(ctx, bucketName, prefix, func(object types.Object) error {
+		if latest == nil || aws.ToTime(object.LastModified).After(aws.ToTime(latest.LastModified)) {
+			latest = &object
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil {
+		return nil, ErrNoObjects
+	}
+	return latest, nil
+}
+
+// FindDuplicates groups the keys under a prefix by ETag and returns the groups holding
+// more than one key. For objects uploaded in a single part the ETag is the MD5 of the
+// content, so equal ETags mean equal content. Multipart ETags (ending in "-<parts>")
+// depend on the part size rather than only the content, so those objects are skipped.
+// ETags of objects encrypted with SSE-KMS or SSE-C aren't MD5s either, so duplicates
+// among them usually go undetected.
+func (service *s3Service) FindDuplicates(ctx context.Context, bucketName string, prefix string) (map[string][]string, error) {
+	groups := map[string][]string{}
+	err := service.walkObjects(ctx, bucketName, prefix, func(object types.Object) error {
+		etag := strings.Trim(aws.ToString(object.ETag), `"`)
+		if etag == "" || strings.Contains(etag, "-") {
+			return nil
+		}
+		groups[etag] = append(groups[etag], aws.ToString(object.Key))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for etag, keys := range groups {
+		if len(keys) < 2 {
+			delete(groups, etag)
+		}
+	}
+	return groups, nil
+}
+
+// IsPrefixEmpty reports whether no object exists under a prefix. An empty prefix
+// checks the whole bucket.
+func (service *s3Service) IsPrefixEmpty(ctx context.Context, bucketName string, prefix string) (bool, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucketName),
+		MaxKeys: aws.Int32(1),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	result, err := service.s3Client.ListObjectsV2(ctx, input)
+	if err != nil {
+		log.Printf("Couldn't list objects in bucket %v. Here's why: %v\n", bucketName, err)
+		return false, err
+	}
+	return len(result.Contents) == 0, nil
+}
+
+const (
+	waitEmptyInitialDelay = time.Second
+	waitEmptyMaxDelay     = 30 * time.Second
+)
+
+// WaitUntilEmpty polls a prefix with exponential backoff until it holds no objects,
+// e.g. while lifecycle expiration drains it. It gives up after timeout with an error
+// wrapping context.DeadlineExceeded.
+func (service *s3Service) WaitUntilEmpty(ctx context.Context, bucketName string, prefix string,
+	timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := waitEmptyInitialDelay
+	for {
+		empty, err := service.IsPrefixEmpty(ctx, bucketName, prefix)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		if empty {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("%v:%v still not empty after %v: %w", bucketName, prefix, timeout, ctx.Err())
+			log.Printf("Couldn't wait for prefix to drain. Here's why: %v\n", err)
+			return err
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, waitEmptyMaxDelay)
+	}
+}
+
+// ClassStats is the number of objects and bytes stored in one storage class.
+type ClassStats struct {
+	Count      int64
+	TotalBytes int64
+}
+
+// StorageClassBreakdown totals the objects under a prefix by storage class, from the
+// storage class each listed object reports. S3 leaves it empty for some objects, and
+// those are counted as STANDARD.
+func (service *s3Service) StorageClassBreakdown(ctx context.Context, bucketName string,
+	prefix string) (map[types.ObjectStorageClass]ClassStats, error) {
+	breakdown := map[types.ObjectStorageClass]ClassStats{}
+	err := service.walkObjects(ctx, bucketName, prefix, func(object types.Object) error {
+		class := object.StorageClass
+		if class == "" {
+			class = types.ObjectStorageClassStandard
+		}
+		stats := breakdown[class]
+		stats.Count++
+		stats.TotalBytes += aws.ToInt64(object.Size)
+		breakdown[class] = stats
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return breakdown, nil
+}
+
+// ListDirectory lists one level under prefix, like ls of a directory: the common
+// prefixes ending in "/" are the folders and the objects directly under prefix are the
+// files. prefix is treated as a folder with or without its trailing slash, and an
+// empty prefix lists the root. Folders keep their full prefix, e.g. "photos/2024/".
+// The zero-byte marker object some tools create for the folder itself is left out.
+func (service *s3Service) ListDirectory(ctx context.Context, bucketName string, prefix string) ([]string, []types.Object, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(bucketName),
+		Delimiter: aws.String("/"),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	var folders []string
+	var files []types.Object
+	paginator := s3.NewListObjectsV2Paginator(service.s3Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Couldn't list directory %v of bucket %v. Here's why: %v\n", prefix, bucketName, err)
+			return nil, nil, err
+		}
+		for _, commonPrefix := range page.CommonPrefixes {
+			folders = append(folders, aws.ToString(commonPrefix.Prefix))
+		}
+		for _, object := range page.Contents {
+			if aws.ToString(object.Key) != prefix {
+				files = append(files, object)
+			}
+		}
+	}
+	return folders, files, nil
+}
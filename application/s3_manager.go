@@ -0,0 +1,58 @@
+package application
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type s3Manager struct {
+	options s3.Options
+	base    *s3Service
+	mutex   sync.Mutex
+	regions map[string]string
+	clients map[string]*s3Service
+}
+
+// NewS3Manager creates a manager that hands out one service per Region, all
+// sharing the credentials and settings of the given base options.
+func NewS3Manager(options s3.Options) *s3Manager {
+	base := NewS3Service(options)
+	return &s3Manager{
+		options: options,
+		base:    base,
+		regions: map[string]string{},
+		clients: map[string]*s3Service{options.Region: base},
+	}
+}
+
+// ClientFor returns a service whose client is pinned to the Region the bucket
+// lives in. Bucket Regions and services are resolved once and reused afterwards.
+func (manager *s3Manager) ClientFor(ctx context.Context, bucketName string) (*s3Service, error) {
+	manager.mutex.Lock()
+	region, found := manager.regions[bucketName]
+	manager.mutex.Unlock()
+
+	if !found {
+		var err error
+		region, err = manager.base.GetBucketRegion(ctx, bucketName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	manager.regions[bucketName] = region
+	if service, ok := manager.clients[region]; ok {
+		return service, nil
+	}
+
+	options := manager.options.Copy()
+	options.Region = region
+	service := NewS3Service(options)
+	manager.clients[region] = service
+	return service, nil
+}
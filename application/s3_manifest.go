@@ -0,0 +1,96 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ManifestEntry is one object listed in a manifest. A manifest is a stream of these
+// entries encoded as JSON, one per line.
+type ManifestEntry struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+	ETag string `json:"etag"`
+}
+
+// WriteManifest writes a manifest of every object under a prefix to w, in key order.
+func (service *s3Service) WriteManifest(ctx context.Context, bucketName string, prefix string, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	return service.walkObjects(ctx, bucketName, prefix, func(object types.Object) error {
+		err := encoder.Encode(ManifestEntry{
+			Key:  aws.ToString(object.Key),
+			Size: aws.ToInt64(object.Size),
+			ETag: aws.ToString(object.ETag),
+		})
+		if err != nil {
+			log.Printf("Couldn't write manifest entry for %v:%v. Here's why: %v\n",
+				bucketName, aws.ToString(object.Key), err)
+		}
+		return err
+	})
+}
+
+// DownloadFromManifest downloads every object listed in a manifest read from r into
+// localDir, recreating each key as a relative path under it. Objects are downloaded
+// concurrently and each file's size is checked against its manifest entry. It returns
+// how many files were restored; the error joins the failures of individual entries,
+// including size mismatches.
+func (service *s3Service) DownloadFromManifest(ctx context.Context, bucketName string, r io.Reader,
+	localDir string) (int, error) {
+	var entries []ManifestEntry
+	decoder := json.NewDecoder(r)
+	for {
+		var entry ManifestEntry
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Couldn't parse manifest. Here's why: %v\n", err)
+			return 0, err
+		}
+		if strings.HasSuffix(entry.Key, "/") {
+			// Folder placeholder, nothing to restore.
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	var downloaded atomic.Int64
+	err := service.forEach(ctx, 0, len(entries), func(ctx context.Context, i int) error {
+		entry := entries[i]
+		relPath := filepath.FromSlash(entry.Key)
+		if !filepath.IsLocal(relPath) {
+			return fmt.Errorf("%v: key escapes the destination directory", entry.Key)
+		}
+		fileName := filepath.Join(localDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			return fmt.Errorf("%v: %w", entry.Key, err)
+		}
+		if err := service.downloadFile(ctx, bucketName, entry.Key, fileName); err != nil {
+			return fmt.Errorf("%v: %w", entry.Key, err)
+		}
+		stat, err := os.Stat(fileName)
+		if err != nil {
+			return fmt.Errorf("%v: %w", entry.Key, err)
+		}
+		if stat.Size() != entry.Size {
+			err = fmt.Errorf("%v: downloaded %v bytes but the manifest lists %v", entry.Key, stat.Size(), entry.Size)
+			log.Printf("Size mismatch restoring %v:%v. Here's why: %v\n", bucketName, entry.Key, err)
+			return err
+		}
+		downloaded.Add(1)
+		return nil
+	})
+	return int(downloaded.Load()), err
+}
@@ -0,0 +1,160 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// objectHeaders points at the header fields of a request that writes an object, so that
+// PutObject and CopyObject carry over the same set from an ObjectInfo.
+type objectHeaders struct {
+	contentType          **string
+	cacheControl         **string
+	contentLanguage      **string
+	contentEncoding      **string
+	contentDisposition   **string
+	expires              **time.Time
+	metadata             *map[string]string
+	storageClass         *types.StorageClass
+	serverSideEncryption *types.ServerSideEncryption
+	sseKMSKeyId          **string
+}
+
+// setFrom sets every header info has: the content headers, Expires, user metadata,
+// storage class and KMS key.
+func (headers objectHeaders) setFrom(info *ObjectInfo) {
+	for _, header := range []struct {
+		value string
+		field **string
+	}{
+		{info.ContentType, headers.contentType},
+		{info.CacheControl, headers.cacheControl},
+		{info.ContentLanguage, headers.contentLanguage},
+		{info.ContentEncoding, headers.contentEncoding},
+		{info.ContentDisposition, headers.contentDisposition},
+	} {
+		if header.value != "" {
+			*header.field = aws.String(header.value)
+		}
+	}
+	if !info.Expires.IsZero() {
+		*headers.expires = aws.Time(info.Expires)
+	}
+	*headers.metadata = info.Metadata
+	*headers.storageClass = info.StorageClass
+	if info.ServerSideEncryption == types.ServerSideEncryptionAwsKms {
+		*headers.serverSideEncryption = info.ServerSideEncryption
+		*headers.sseKMSKeyId = aws.String(info.SSEKMSKeyId)
+	}
+}
+
+// rewriteHeaders changes the headers of an object without touching its body, by copying
+// the object onto itself with the REPLACE metadata directive. REPLACE drops every header
+// that isn't sent again, so the current ones are read with HeadObject, passed to edit,
+// and all of them are sent with the copy, together with the storage class and KMS key.
+// Like any CopyObject, it only works for objects up to 5 GiB.
+func (service *s3Service) rewriteHeaders(ctx context.Context, bucketName string, objectKey string,
+	edit func(info *ObjectInfo)) error {
+	service.invalidateInfo(bucketName, objectKey)
+	info, err := service.GetObjectInfo(ctx, bucketName, objectKey)
+	if err != nil {
+		return err
+	}
+	edit(info)
+	input := &s3.CopyObjectInput{
+		Bucket:            aws.String(bucketName),
+		CopySource:        aws.String(copySource(bucketName, objectKey)),
+		Key:               aws.String(objectKey),
+		MetadataDirective: types.MetadataDirectiveReplace,
+		// The source ETag guards against overwriting a body written since HeadObject.
+		CopySourceIfMatch: aws.String(info.ETag),
+	}
+	objectHeaders{
+		&input.ContentType, &input.CacheControl, &input.ContentLanguage, &input.ContentEncoding,
+		&input.ContentDisposition, &input.Expires, &input.Metadata, &input.StorageClass,
+		&input.ServerSideEncryption, &input.SSEKMSKeyId,
+	}.setFrom(info)
+	_, err = service.s3Client.CopyObject(ctx, input)
+	service.invalidateInfo(bucketName, objectKey)
+	if err != nil {
+		log.Printf("Couldn't rewrite headers of %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+	}
+	return err
+}
+
+// GetUserMetadata returns the user-defined (x-amz-meta-*) metadata of an object. S3
+// returns the keys in lower case.
+func (service *s3Service) GetUserMetadata(ctx context.Context, bucketName string, objectKey string) (map[string]string, error) {
+	info, err := service.GetObjectInfo(ctx, bucketName, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	return info.Metadata, nil
+}
+
+// SetMetadata replaces the user-defined metadata of an object with metadata, or adds
+// metadata to what is already there when merge is true, without uploading the body
+// again. The other headers are kept. The result is read back with GetUserMetadata.
+func (service *s3Service) SetMetadata(ctx context.Context, bucketName string, objectKey string,
+	metadata map[string]string, merge bool) error {
+	// S3 stores metadata keys in lower case, so merging and comparing happen in it too.
+	wanted := make(map[string]string, len(metadata))
+	err := service.rewriteHeaders(ctx, bucketName, objectKey, func(info *ObjectInfo) {
+		if merge {
+			for key, value := range info.Metadata {
+				wanted[strings.ToLower(key)] = value
+			}
+		}
+		for key, value := range metadata {
+			wanted[strings.ToLower(key)] = value
+		}
+		info.Metadata = wanted
+	})
+	if err != nil {
+		return err
+	}
+
+	stored, err := service.GetUserMetadata(ctx, bucketName, objectKey)
+	if err != nil {
+		return err
+	}
+	if !maps.Equal(stored, wanted) {
+		err = fmt.Errorf("object %v:%v has metadata %v after setting %v", bucketName, objectKey, stored, wanted)
+		log.Printf("Couldn't verify metadata. Here's why: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// SetContentType changes the content type of an object without uploading the body
+// again, keeping its user metadata and other headers, and checks the result with
+// GetObjectInfo.
+func (service *s3Service) SetContentType(ctx context.Context, bucketName string, objectKey string,
+	contentType string) error {
+	err := service.rewriteHeaders(ctx, bucketName, objectKey, func(info *ObjectInfo) {
+		info.ContentType = contentType
+	})
+	if err != nil {
+		return err
+	}
+
+	info, err := service.GetObjectInfo(ctx, bucketName, objectKey)
+	if err != nil {
+		return err
+	}
+	if info.ContentType != contentType {
+		err = fmt.Errorf("object %v:%v has content type %q after setting %q",
+			bucketName, objectKey, info.ContentType, contentType)
+		log.Printf("Couldn't verify content type. Here's why: %v\n", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// MigrateObject streams an object from the src service, which may point to another
+// endpoint or provider, into dstBucket/dstKey of this service, keeping its content
+// type. The body passes through this process without being buffered whole.
+func (service *s3Service) MigrateObject(ctx context.Context, src *s3Service, srcBucket string, srcKey string,
+	dstBucket string, dstKey string) error {
+	return service.StreamCopyObject(ctx, src, srcBucket, srcKey, dstBucket, dstKey, nil)
+}
+
+// progressReader calls progress with the running total of bytes read through it, and
+// fails with io.ErrUnexpectedEOF when the body ends before total bytes.
+type progressReader struct {
+	reader   io.Reader
+	read     int64
+	total    int64
+	progress func(copied int64, total int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.read += int64(n)
+		r.progress(r.read, r.total)
+	}
+	if err == io.EOF && r.read != r.total {
+		err = fmt.Errorf("%w: got %v of %v bytes", io.ErrUnexpectedEOF, r.read, r.total)
+	}
+	return n, err
+}
+
+// StreamCopyObject works like MigrateObject, calling progress with the number of bytes
+// copied so far and the size of the source object as the body streams through. progress
+// may be nil.
+//
+// The source is read with a fresh HeadObject, not the src cache, and its body is pinned
+// to that ETag, so an object overwritten in between fails with PreconditionFailed instead
+// of mixing versions. A body that ends short of the size fails the upload, so the
+// destination never holds a partial copy.
+func (service *s3Service) StreamCopyObject(ctx context.Context, src *s3Service, srcBucket string, srcKey string,
+	dstBucket string, dstKey string, progress func(copied int64, total int64)) error {
+	src.invalidateInfo(srcBucket, srcKey)
+	info, err := src.GetObjectInfo(ctx, srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	body, err := src.openObject(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(srcBucket),
+		Key:     aws.String(srcKey),
+		IfMatch: aws.String(info.ETag),
+	})
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if progress == nil {
+		progress = func(int64, int64) {}
+	}
+	reader := &progressReader{reader: body, total: info.Size, progress: progress}
+	_, err = service.UploadReader(ctx, dstBucket, dstKey, reader, UploadOptions{ContentType: info.ContentType})
+	return err
+}
+
+// MigratePrefix migrates every object under srcPrefix in the src service to this
+// service, replacing srcPrefix with dstPrefix in the keys. Objects are migrated
+// concurrently; the error joins the failures of individual objects.
+func (service *s3Service) MigratePrefix(ctx context.Context, src *s3Service, srcBucket string, srcPrefix string,
+	dstBucket string, dstPrefix string) (int, error) {
+	var keys []string
+	err := src.walkObjects(ctx, srcBucket, srcPrefix, func(object types.Object) error {
+		keys = append(keys, aws.ToString(object.Key))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var migrated atomic.Int64
+	err = service.forEach(ctx, 0, len(keys), func(ctx context.Context, i int) error {
+		dstKey := dstPrefix + strings.TrimPrefix(keys[i], srcPrefix)
+		if err := service.MigrateObject(ctx, src, srcBucket, keys[i], dstBucket, dstKey); err != nil {
+			return fmt.Errorf("%v: %w", keys[i], err)
+		}
+		migrated.Add(1)
+		return nil
+	})
+	return int(migrated.Load()), err
+}
@@ -0,0 +1,60 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestStreamCopyObjectProgress(t *testing.T) {
+	ctx := context.Background()
+	src, srcFake := newFakeService(t, "src")
+	dst, _ := newFakeService(t, "dst")
+	putFakeObject(t, srcFake, "src", "key", "hello")
+
+	var copied, total int64
+	err := dst.StreamCopyObject(ctx, src, "src", "key", "dst", "key", func(n int64, size int64) {
+		copied, total = n, size
+	})
+	if err != nil {
+		t.Fatalf("StreamCopyObject: %v", err)
+	}
+	if copied != 5 || total != 5 {
+		t.Errorf("last progress = %v of %v, want 5 of 5", copied, total)
+	}
+}
+
+// truncatingS3 returns only the first two bytes of every object body.
+type truncatingS3 struct {
+	*FakeS3
+}
+
+func (fake truncatingS3) GetObject(ctx context.Context, params *s3.GetObjectInput,
+	optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	output, err := fake.FakeS3.GetObject(ctx, params, optFns...)
+	if err == nil {
+		output.Body = io.NopCloser(io.LimitReader(output.Body, 2))
+	}
+	return output, err
+}
+
+func TestStreamCopyObjectRejectsShortBodies(t *testing.T) {
+	ctx := context.Background()
+	_, srcFake := newFakeService(t, "src")
+	src := NewS3ServiceWithClient(truncatingS3{srcFake})
+	dst, _ := newFakeService(t, "dst")
+	putFakeObject(t, srcFake, "src", "key", "hello")
+
+	err := dst.StreamCopyObject(ctx, src, "src", "key", "dst", "key", nil)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("error = %v, want io.ErrUnexpectedEOF", err)
+	}
+	var notFound *types.NotFound
+	if _, err := dst.GetObjectInfo(ctx, "dst", "key"); !errors.As(err, &notFound) {
+		t.Errorf("a partial copy was written: GetObjectInfo error = %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package application
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// UploadOptions holds the optional headers set on objects when they are uploaded.
+// Empty fields are left out of the request.
+type UploadOptions struct {
+	ContentType     string
+	CacheControl    string
+	ContentLanguage string
+	// ContentEncoding is sent as is; the body must already be encoded with it
+	// (e.g. "br" for a Brotli-compressed asset). Nothing is compressed here.
+	ContentEncoding string
+	Metadata        map[string]string
+	// StorageClass picks where the object is stored, e.g.
+	// types.StorageClassIntelligentTiering. Empty means STANDARD.
+	StorageClass types.StorageClass
+	// CustomerKey encrypts the object with a customer-provided key (SSE-C). The same
+	// key must then be passed to read it back, e.g. in DownloadOptions.
+	CustomerKey *CustomerKey
+}
+
+func (options UploadOptions) applyTo(input *s3.PutObjectInput) {
+	if options.ContentType != "" {
+		input.ContentType = aws.String(options.ContentType)
+	}
+	if options.CacheControl != "" {
+		input.CacheControl = aws.String(options.CacheControl)
+	}
+	if options.ContentLanguage != "" {
+		input.ContentLanguage = aws.String(options.ContentLanguage)
+	}
+	if options.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(options.ContentEncoding)
+	}
+	if len(options.Metadata) > 0 {
+		input.Metadata = options.Metadata
+	}
+	input.StorageClass = options.StorageClass
+	options.CustomerKey.set(&input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
+}
+
+// DownloadOptions holds the optional settings of a single read of an object.
+type DownloadOptions struct {
+	// CustomerKey is the key an SSE-C object was written with. Reading such an object
+	// without it fails with ErrEncryptionKeyRequired.
+	CustomerKey *CustomerKey
+}
+
+func (options DownloadOptions) applyTo(input *s3.GetObjectInput) {
+	options.CustomerKey.set(&input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
+}
+
+// CopyOptions holds the optional settings of a single copy.
+type CopyOptions struct {
+	// SourceCustomerKey decrypts an SSE-C source object and CustomerKey encrypts the
+	// copy. Either may be nil, so a copy can add, change or remove SSE-C.
+	SourceCustomerKey *CustomerKey
+	CustomerKey       *CustomerKey
+}
+
+func (options CopyOptions) applyTo(input *s3.CopyObjectInput) {
+	options.SourceCustomerKey.set(&input.CopySourceSSECustomerAlgorithm, &input.CopySourceSSECustomerKey,
+		&input.CopySourceSSECustomerKeyMD5)
+	options.CustomerKey.set(&input.SSECustomerAlgorithm, &input.SSECustomerKey, &input.SSECustomerKeyMD5)
+}
+
+// CreateBucketOptions holds the optional access settings of a new bucket.
+//
+// ObjectOwnership defaults to BucketOwnerEnforced, the setting AWS recommends, which
+// disables ACLs entirely: with it, only the "private" ACL (or none) is accepted. Pick
+// BucketOwnerPreferred or ObjectWriter to use any other canned ACL. Public ACLs such
+// as public-read are still rejected while Block Public Access is on for the bucket or
+// the account, which is the default for new buckets.
+type CreateBucketOptions struct {
+	ACL             types.BucketCannedACL
+	ObjectOwnership types.ObjectOwnership
+}
+
+func (options CreateBucketOptions) applyTo(input *s3.CreateBucketInput) {
+	input.ACL = options.ACL
+	input.ObjectOwnership = options.ObjectOwnership
+	if input.ObjectOwnership == "" {
+		input.ObjectOwnership = types.ObjectOwnershipBucketOwnerEnforced
+	}
+}
+
+// DeleteOptions holds the optional settings of a delete.
+type DeleteOptions struct {
+	// BypassGovernanceRetention removes object versions locked in governance mode,
+	// which needs the s3:BypassGovernanceRetention permission; without that permission
+	// the delete fails with ErrGovernanceBypassDenied. Compliance-mode locks can't be
+	// bypassed.
+	//
+	// Only deletes of a specific version, like DeleteObjectVersion, can hit a lock.
+	// Object Lock needs a versioned bucket, where deletes by key (DeleteObject,
+	// DeleteObjects, DeleteOlderThan, DeleteExpired) just add a delete marker and keep
+	// the locked version, so the flag changes nothing for them.
+	BypassGovernanceRetention bool
+}
+
+func (options DeleteOptions) bypass() *bool {
+	if !options.BypassGovernanceRetention {
+		return nil
+	}
+	return aws.Bool(true)
+}
+
+// TransportOptions tunes the connection pool of the HTTP client used to reach S3. Zero
+// fields keep the SDK defaults (100 idle connections, 10 per host, 90s idle timeout).
+//
+// Every concurrent request needs its own connection, and S3 is a single host, so
+// MaxIdleConnsPerHost is the limit that matters: when it is below the number of
+// requests in flight, connections beyond it are closed after each request and opened
+// again (with a new TLS handshake) for the next one. Size it to at least
+// MaxConcurrency, or to the batch concurrency times the transfer manager concurrency
+// when MaxConcurrency is unset.
+type TransportOptions struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+}
+
+// TunedTransport returns transport options sized for bulk operations running many
+// requests at once.
+func TunedTransport() TransportOptions {
+	return TransportOptions{
+		MaxIdleConns:        256,
+		MaxIdleConnsPerHost: 128,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// Apply sets the HTTP client of options to one built from these transport options.
+// Its signature lets it also be passed to s3.New as an option function.
+func (transport TransportOptions) Apply(options *s3.Options) {
+	options.HTTPClient = awshttp.NewBuildableClient().WithTransportOptions(func(t *http.Transport) {
+		if transport.MaxIdleConns > 0 {
+			t.MaxIdleConns = transport.MaxIdleConns
+		}
+		if transport.MaxIdleConnsPerHost > 0 {
+			t.MaxIdleConnsPerHost = transport.MaxIdleConnsPerHost
+		}
+		if transport.IdleConnTimeout > 0 {
+			t.IdleConnTimeout = transport.IdleConnTimeout
+		}
+	})
+}
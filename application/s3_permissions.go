@@ -0,0 +1,160 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// PermissionReport tells which kinds of requests the current credentials may make on
+// a bucket. A false value with no entry in Errors means S3 answered AccessDenied;
+// Errors holds any other failure, keyed by "metadata", "list", "write", "read" or
+// "delete", in which case the permission is unknown.
+type PermissionReport struct {
+	ReadMetadata bool
+	List         bool
+	Write        bool
+	Read         bool
+	Delete       bool
+	Errors       map[string]error
+}
+
+// isAccessDenied reports whether S3 refused a request for lack of permissions.
+func isAccessDenied(err error) bool {
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) && (apiError.ErrorCode() == "AccessDenied" || apiError.ErrorCode() == "Forbidden") {
+		return true
+	}
+	var withStatus interface{ HTTPStatusCode() int }
+	return errors.As(err, &withStatus) && withStatus.HTTPStatusCode() == 403
+}
+
+// DryRunCheck probes a bucket with harmless requests to find out what the current
+// credentials can do before a job starts: HeadBucket for metadata, an empty listing,
+// and a zero-byte temporary object that is written (only if the key is free), read
+// back and deleted again.
+func (service *s3Service) DryRunCheck(ctx context.Context, bucketName string) (*PermissionReport, error) {
+	report := &PermissionReport{Errors: map[string]error{}}
+	record := func(probe string, err error) bool {
+		if err == nil {
+			return true
+		}
+		if ctx.Err() == nil && !isAccessDenied(err) {
+			report.Errors[probe] = err
+		}
+		return false
+	}
+
+	_, err := service.s3Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucketName)})
+	report.ReadMetadata = record("metadata", err)
+
+	_, err = service.s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucketName),
+		MaxKeys: aws.Int32(0),
+	})
+	report.List = record("list", err)
+
+	suffix := make([]byte, 8)
+	if _, err = rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	probeKey := ".permission-check-" + hex.EncodeToString(suffix)
+	_, err = service.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(bucketName),
+		Key:           aws.String(probeKey),
+		Body:          strings.NewReader(""),
+		ContentLength: aws.Int64(0),
+		IfNoneMatch:   aws.String("*"),
+	})
+	report.Write = record("write", err)
+
+	if report.Write {
+		result, err := service.s3Client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(probeKey),
+		})
+		if err == nil {
+			io.Copy(io.Discard, result.Body)
+			result.Body.Close()
+		}
+		report.Read = record("read", err)
+
+		_, err = service.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(probeKey),
+		})
+		report.Delete = record("delete", err)
+		if !report.Delete {
+			log.Printf("Couldn't remove permission probe %v:%v, delete it manually.\n", bucketName, probeKey)
+		}
+	} else {
+		notProbed := errors.New("not probed because the write probe failed")
+		report.Errors["read"] = notProbed
+		report.Errors["delete"] = notProbed
+	}
+	return report, ctx.Err()
+}
+
+// ErrInvalidCredentials is returned by VerifyCredentials when the credentials are
+// missing, empty or rejected by S3.
+var ErrInvalidCredentials = errors.New("credentials are empty or invalid")
+
+// isCredentialError reports whether S3 rejected the credentials of a request, as
+// opposed to refusing the request they were valid for.
+func isCredentialError(err error) bool {
+	var apiError smithy.APIError
+	if !errors.As(err, &apiError) {
+		return false
+	}
+	switch apiError.ErrorCode() {
+	case "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "InvalidToken", "TokenRefreshRequired":
+		return true
+	}
+	return false
+}
+
+// VerifyCredentials checks that the service has credentials and that S3 accepts them,
+// so a misconfigured environment fails early with ErrInvalidCredentials instead of
+// deep inside the first real request. It costs one ListBuckets call; AccessDenied on
+// it still means the credentials are valid. It is never called implicitly.
+func (service *s3Service) VerifyCredentials(ctx context.Context) error {
+	// Services built on a custom client have no options to inspect.
+	if _, ok := service.s3Client.(*s3.Client); ok {
+		if service.options.Credentials == nil {
+			err := fmt.Errorf("%w: no credentials provider is configured", ErrInvalidCredentials)
+			log.Printf("Couldn't verify credentials. Here's why: %v\n", err)
+			return err
+		}
+		credentials, err := service.options.Credentials.Retrieve(ctx)
+		if err != nil {
+			err = fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+			log.Printf("Couldn't verify credentials. Here's why: %v\n", err)
+			return err
+		}
+		if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+			err = fmt.Errorf("%w: the access key ID or secret access key is empty", ErrInvalidCredentials)
+			log.Printf("Couldn't verify credentials. Here's why: %v\n", err)
+			return err
+		}
+	}
+	_, err := service.s3Client.ListBuckets(ctx, &s3.ListBucketsInput{MaxBuckets: aws.Int32(1)})
+	switch {
+	case err == nil:
+		return nil
+	case isCredentialError(err):
+		err = fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	case isAccessDenied(err):
+		return nil
+	}
+	log.Printf("Couldn't verify credentials. Here's why: %v\n", err)
+	return err
+}
@@ -0,0 +1,231 @@
+package application
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// maxPresignExpiry is the longest validity SigV4 allows for a presigned request.
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+func validatePresignExpiry(expiry time.Duration) error {
+	if expiry <= 0 || expiry > maxPresignExpiry {
+		return fmt.Errorf("presign expiry must be between 0 and %v, got %v", maxPresignExpiry, expiry)
+	}
+	return nil
+}
+
+// PresignedPost is a browser-based upload form: POST the fields, followed by a "file"
+// field holding the content, as multipart/form-data to URL.
+type PresignedPost struct {
+	URL    string
+	Fields map[string]string
+}
+
+// PresignPost signs a POST policy that lets anyone holding the form upload exactly one
+// object, at objectKey, until expiry passes.
+//
+// A POST policy can only constrain form fields (bucket, key, content length, ...). It
+// can't restrict who sends the form, so a source IP restriction has to live in the
+// bucket policy instead; see PutSourceIPPolicy.
+func (service *s3Service) PresignPost(ctx context.Context, bucketName string, objectKey string,
+	expiry time.Duration) (*PresignedPost, error) {
+	if err := validatePresignExpiry(expiry); err != nil {
+		return nil, err
+	}
+	if service.options.Credentials == nil {
+		return nil, errors.New("presigning needs a service created from client options")
+	}
+	credentials, err := service.options.Credentials.Retrieve(ctx)
+	if err != nil {
+		log.Printf("Couldn't retrieve credentials to presign a POST to %v:%v. Here's why: %v\n",
+			bucketName, objectKey, err)
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	region := service.options.Region
+	fields := map[string]string{
+		"key":              objectKey,
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": fmt.Sprintf("%v/%v/%v/s3/aws4_request", credentials.AccessKeyID, date, region),
+		"x-amz-date":       now.Format("20060102T150405Z"),
+	}
+	if credentials.SessionToken != "" {
+		fields["x-amz-security-token"] = credentials.SessionToken
+	}
+	conditions := []any{map[string]string{"bucket": bucketName}}
+	for name, value := range fields {
+		conditions = append(conditions, map[string]string{name: value})
+	}
+	policy, err := json.Marshal(map[string]any{
+		"expiration": now.Add(expiry).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fields["policy"] = base64.StdEncoding.EncodeToString(policy)
+
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(key, fields["policy"]))
+
+	url := fmt.Sprintf("https://%v.s3.%v.amazonaws.com/", bucketName, region)
+	if service.options.BaseEndpoint != nil {
+		url = strings.TrimSuffix(*service.options.BaseEndpoint, "/") + "/" + bucketName + "/"
+	}
+	return &PresignedPost{URL: url, Fields: fields}, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(data))
+	return hash.Sum(nil)
+}
+
+// sourceIPStatementID is the Sid of the statement SourceIPPolicy builds, by which
+// PutSourceIPPolicy finds it again in an existing bucket policy.
+const sourceIPStatementID = "DenyPostUploadsOutsideSourceIP"
+
+// sourceIPStatement denies browser-based POST uploads under keyPrefix from any address
+// outside cidr. The s3:authType condition limits it to POST, so uploads signed with
+// headers or query strings, like those of UploadFile and the SDK, still work from
+// anywhere.
+func sourceIPStatement(bucketName string, keyPrefix string, cidr string) (map[string]any, error) {
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"Sid":       sourceIPStatementID,
+		"Effect":    "Deny",
+		"Principal": "*",
+		"Action":    "s3:PutObject",
+		"Resource":  fmt.Sprintf("arn:aws:s3:::%v/%v*", bucketName, keyPrefix),
+		"Condition": map[string]any{
+			"StringEquals": map[string]string{"s3:authType": "POST"},
+			"NotIpAddress": map[string]string{"aws:SourceIp": cidr},
+		},
+	}, nil
+}
+
+// SourceIPPolicy returns a bucket policy document that denies presigned POST uploads
+// under keyPrefix from any address outside cidr, which the POST policy itself can't
+// express. Other uploads, the owner's included, aren't affected. Applying the document
+// with PutBucketPolicy replaces the whole policy of the bucket; PutSourceIPPolicy adds
+// the statement to the existing policy instead.
+func SourceIPPolicy(bucketName string, keyPrefix string, cidr string) (string, error) {
+	statement, err := sourceIPStatement(bucketName, keyPrefix, cidr)
+	if err != nil {
+		return "", err
+	}
+	policy, err := json.MarshalIndent(map[string]any{
+		"Version":   "2012-10-17",
+		"Statement": []any{statement},
+	}, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	return string(policy), nil
+}
+
+// PutSourceIPPolicy adds the statement of SourceIPPolicy to the bucket policy, keeping
+// the other statements and replacing one added by an earlier call, so only one source
+// IP range is enforced per bucket. A bucket without a policy gets a new one.
+func (service *s3Service) PutSourceIPPolicy(ctx context.Context, bucketName string, keyPrefix string,
+	cidr string) error {
+	statement, err := sourceIPStatement(bucketName, keyPrefix, cidr)
+	if err != nil {
+		log.Printf("Couldn't build the source IP policy of bucket %v. Here's why: %v\n", bucketName, err)
+		return err
+	}
+	policy := map[string]any{"Version": "2012-10-17"}
+	result, err := service.s3Client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(bucketName)})
+	var apiError smithy.APIError
+	switch {
+	case err == nil:
+		if err = json.Unmarshal([]byte(aws.ToString(result.Policy)), &policy); err != nil {
+			log.Printf("Couldn't parse the policy of bucket %v. Here's why: %v\n", bucketName, err)
+			return err
+		}
+	case errors.As(err, &apiError) && apiError.ErrorCode() == "NoSuchBucketPolicy":
+	default:
+		log.Printf("Couldn't get the policy of bucket %v. Here's why: %v\n", bucketName, err)
+		return err
+	}
+
+	// Statement may be a single object or a list.
+	var statements []any
+	switch existing := policy["Statement"].(type) {
+	case []any:
+		statements = existing
+	case map[string]any:
+		statements = []any{existing}
+	}
+	merged := []any{}
+	for _, existing := range statements {
+		if fields, ok := existing.(map[string]any); !ok || fields["Sid"] != sourceIPStatementID {
+			merged = append(merged, existing)
+		}
+	}
+	policy["Statement"] = append(merged, statement)
+	document, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	_, err = service.s3Client.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
+		Bucket: aws.String(bucketName),
+		Policy: aws.String(string(document)),
+	})
+	if err != nil {
+		log.Printf("Couldn't put the policy of bucket %v. Here's why: %v\n", bucketName, err)
+	}
+	return err
+}
+
+// presignClient returns a presigner built from the client options of the service.
+func (service *s3Service) presignClient() (*s3.PresignClient, error) {
+	if service.options.Credentials == nil {
+		return nil, errors.New("presigning needs a service created from client options")
+	}
+	return s3.NewPresignClient(s3.New(service.options)), nil
+}
+
+// PresignHead returns a URL that lets anyone send a HEAD request for an object until
+// expiry passes, e.g. for a browser to check its ETag and size without downloading it.
+func (service *s3Service) PresignHead(ctx context.Context, bucketName string, objectKey string,
+	expiry time.Duration) (string, error) {
+	if err := validatePresignExpiry(expiry); err != nil {
+		return "", err
+	}
+	presigner, err := service.presignClient()
+	if err != nil {
+		return "", err
+	}
+	request, err := presigner.PresignHeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		log.Printf("Couldn't presign a HEAD request for %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return "", err
+	}
+	return request.URL, nil
+}
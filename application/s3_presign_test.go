@@ -0,0 +1,57 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestPutSourceIPPolicyMerges(t *testing.T) {
+	ctx := context.Background()
+	service, fake := newFakeService(t, "bucket")
+	if err := service.PutSourceIPPolicy(ctx, "bucket", "uploads/", "not-a-cidr"); err == nil {
+		t.Errorf("an invalid CIDR was accepted")
+	}
+
+	existing := `{"Version":"2012-10-17","Statement":{"Sid":"AllowLogs","Effect":"Allow",` +
+		`"Principal":{"Service":"logging.s3.amazonaws.com"},"Action":"s3:PutObject","Resource":"arn:aws:s3:::bucket/logs/*"}}`
+	if _, err := fake.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
+		Bucket: aws.String("bucket"),
+		Policy: aws.String(existing),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, cidr := range []string{"10.0.0.0/8", "192.0.2.0/24"} {
+		if err := service.PutSourceIPPolicy(ctx, "bucket", "uploads/", cidr); err != nil {
+			t.Fatalf("PutSourceIPPolicy(%v): %v", cidr, err)
+		}
+	}
+
+	result, err := fake.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String("bucket")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var policy struct {
+		Statement []struct {
+			Sid       string
+			Condition map[string]map[string]string
+		}
+	}
+	if err := json.Unmarshal([]byte(aws.ToString(result.Policy)), &policy); err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Statement) != 2 || policy.Statement[0].Sid != "AllowLogs" {
+		t.Fatalf("policy = %v, want the existing statement and one source IP statement",
+			aws.ToString(result.Policy))
+	}
+	condition := policy.Statement[1].Condition
+	if condition["NotIpAddress"]["aws:SourceIp"] != "192.0.2.0/24" {
+		t.Errorf("source IP = %q, want the range of the last call", condition["NotIpAddress"]["aws:SourceIp"])
+	}
+	if condition["StringEquals"]["s3:authType"] != "POST" {
+		t.Errorf("the statement isn't limited to POST uploads: %v", condition)
+	}
+}
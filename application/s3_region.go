@@ -0,0 +1,76 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyendpoints "github.com/aws/smithy-go/endpoints"
+)
+
+// regionResolver sends requests for a bucket to the endpoint of the Region the bucket
+// lives in when AutoRegion is set. S3 signs each request for the Region of its
+// resolved endpoint, so swapping the Region here is enough to route one client to
+// every Region.
+type regionResolver struct {
+	service *s3Service
+	next    s3.EndpointResolverV2
+}
+
+func (resolver *regionResolver) ResolveEndpoint(ctx context.Context, params s3.EndpointParameters) (
+	smithyendpoints.Endpoint, error) {
+	if resolver.service.AutoRegion && params.Bucket != nil {
+		switch awsmiddleware.GetOperationName(ctx) {
+		// Buckets being created don't have a Region yet, and looking one up must not
+		// look itself up.
+		case "CreateBucket", "GetBucketLocation":
+		default:
+			if region, ok := resolver.service.bucketRegion(ctx, aws.ToString(params.Bucket)); ok {
+				params.Region = aws.String(region)
+			}
+		}
+	}
+	return resolver.next.ResolveEndpoint(ctx, params)
+}
+
+// regionLookupRetry is how long a failed Region lookup is remembered before the next
+// request for the bucket tries again.
+const regionLookupRetry = time.Minute
+
+// bucketRegionEntry is a cached Region lookup. Failed lookups have an empty region
+// and expire at retryAt.
+type bucketRegionEntry struct {
+	region  string
+	retryAt time.Time
+}
+
+// bucketRegion returns the Region of a bucket, looking it up on first use. Failed
+// lookups (e.g. without s3:GetBucketLocation) are remembered for regionLookupRetry,
+// and the requests meanwhile go to the configured Region without looking it up again.
+func (service *s3Service) bucketRegion(ctx context.Context, bucketName string) (string, bool) {
+	service.regionsMutex.Lock()
+	entry, found := service.bucketRegions[bucketName]
+	service.regionsMutex.Unlock()
+	if found && (entry.region != "" || time.Now().Before(entry.retryAt)) {
+		return entry.region, entry.region != ""
+	}
+
+	region, err := service.GetBucketRegion(ctx, bucketName)
+	if err != nil && ctx.Err() != nil {
+		// The caller gave up; that says nothing about the bucket.
+		return "", false
+	}
+	entry = bucketRegionEntry{region: region}
+	if err != nil {
+		entry.retryAt = time.Now().Add(regionLookupRetry)
+	}
+	service.regionsMutex.Lock()
+	defer service.regionsMutex.Unlock()
+	if service.bucketRegions == nil {
+		service.bucketRegions = map[string]bucketRegionEntry{}
+	}
+	service.bucketRegions[bucketName] = entry
+	return region, err == nil
+}
@@ -0,0 +1,49 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// countingTransport counts the GetBucketLocation requests and all other requests
+// before passing them on.
+type countingTransport struct {
+	next      http.RoundTripper
+	locations *int
+	others    *int
+}
+
+func (transport countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.URL.Query().Has("location") {
+		*transport.locations++
+	} else {
+		*transport.others++
+	}
+	return transport.next.RoundTrip(request)
+}
+
+func TestFailedRegionLookupIsCached(t *testing.T) {
+	ctx := context.Background()
+	var locations, others int
+	service := newStubService(countingTransport{next: stubTransport{status: 403}, locations: &locations, others: &others})
+	service.AutoRegion = true
+
+	for i := 0; i < 3; i++ {
+		service.GetObjectInfo(ctx, "bucket", "key")
+	}
+	if locations != 1 || others != 3 {
+		t.Errorf("sent %v GetBucketLocation and %v other requests, want 1 and 3", locations, others)
+	}
+
+	service.regionsMutex.Lock()
+	entry := service.bucketRegions["bucket"]
+	entry.retryAt = time.Now().Add(-time.Second)
+	service.bucketRegions["bucket"] = entry
+	service.regionsMutex.Unlock()
+	service.GetObjectInfo(ctx, "bucket", "key")
+	if locations != 2 {
+		t.Errorf("sent %v GetBucketLocation requests after the failure expired, want 2", locations)
+	}
+}
@@ -0,0 +1,98 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type openUpload struct {
+	bucket string
+	key    string
+}
+
+// uploadTracker is the client handed to the upload manager. It records every
+// multipart upload the service starts until it is completed or aborted, so Shutdown
+// can abort the ones left behind.
+type uploadTracker struct {
+	s3API
+	service *s3Service
+}
+
+func (tracker uploadTracker) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput,
+	optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	result, err := tracker.s3API.CreateMultipartUpload(ctx, params, optFns...)
+	if err == nil {
+		tracker.service.trackUpload(aws.ToString(result.UploadId), &openUpload{
+			bucket: aws.ToString(params.Bucket),
+			key:    aws.ToString(params.Key),
+		})
+	}
+	return result, err
+}
+
+func (tracker uploadTracker) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput,
+	optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	result, err := tracker.s3API.CompleteMultipartUpload(ctx, params, optFns...)
+	if err == nil {
+		tracker.service.trackUpload(aws.ToString(params.UploadId), nil)
+	}
+	return result, err
+}
+
+func (tracker uploadTracker) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput,
+	optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	result, err := tracker.s3API.AbortMultipartUpload(ctx, params, optFns...)
+	if err == nil || isMultipartStateError(err) {
+		tracker.service.trackUpload(aws.ToString(params.UploadId), nil)
+	}
+	return result, err
+}
+
+// trackUpload records an open multipart upload, or forgets it when upload is nil.
+func (service *s3Service) trackUpload(uploadID string, upload *openUpload) {
+	service.uploadsMutex.Lock()
+	defer service.uploadsMutex.Unlock()
+
+	if upload == nil {
+		delete(service.openUploads, uploadID)
+		return
+	}
+	if service.openUploads == nil {
+		service.openUploads = map[string]openUpload{}
+	}
+	service.openUploads[uploadID] = *upload
+}
+
+func (service *s3Service) uploadClient() uploadTracker {
+	return uploadTracker{s3API: service.s3Client, service: service}
+}
+
+// Shutdown aborts every multipart upload this service started and hasn't finished,
+// so a process stopping mid-transfer doesn't leave orphaned parts behind.
+func (service *s3Service) Shutdown(ctx context.Context) error {
+	service.uploadsMutex.Lock()
+	uploads := make(map[string]openUpload, len(service.openUploads))
+	for uploadID, upload := range service.openUploads {
+		uploads[uploadID] = upload
+	}
+	service.uploadsMutex.Unlock()
+
+	var errs []error
+	for uploadID, upload := range uploads {
+		_, err := service.uploadClient().AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(upload.bucket),
+			Key:      aws.String(upload.key),
+			UploadId: aws.String(uploadID),
+		})
+		if err != nil && !isMultipartStateError(err) {
+			log.Printf("Couldn't abort multipart upload %v of %v:%v. Here's why: %v\n",
+				uploadID, upload.bucket, upload.key, err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
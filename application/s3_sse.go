@@ -0,0 +1,78 @@
+package application
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// ErrEncryptionKeyRequired is returned when S3 refuses to read an object because it is
+// encrypted with a customer-provided key (SSE-C) and the request didn't carry it.
+var ErrEncryptionKeyRequired = errors.New("object is encrypted with a customer-provided key")
+
+// sseCustomerKeyMessage starts the message of the InvalidRequest error S3 returns for
+// reads and copies of SSE-C objects without the key.
+const sseCustomerKeyMessage = "The object was stored using a form of Server Side Encryption"
+
+// CustomerKey is a customer-provided AES-256 key for SSE-C, with both fields base64
+// encoded as S3 expects them. S3 uses the key to encrypt or decrypt the object and
+// discards it, so objects written with it can't be read without it.
+type CustomerKey struct {
+	Key    string
+	KeyMD5 string
+}
+
+// NewCustomerKey encodes a raw 32-byte AES-256 key as a CustomerKey.
+func NewCustomerKey(key []byte) (*CustomerKey, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("SSE-C keys must be 32 bytes long, got %v", len(key))
+	}
+	sum := md5.Sum(key)
+	return &CustomerKey{
+		Key:    base64.StdEncoding.EncodeToString(key),
+		KeyMD5: base64.StdEncoding.EncodeToString(sum[:]),
+	}, nil
+}
+
+// set fills in the SSE-C fields of a request. A nil key leaves them unset.
+func (key *CustomerKey) set(algorithm **string, value **string, md5 **string) {
+	if key == nil {
+		return
+	}
+	*algorithm = aws.String("AES256")
+	*value = aws.String(key.Key)
+	*md5 = aws.String(key.KeyMD5)
+}
+
+// mapCustomerKeyError turns the answers S3 gives to reads of SSE-C objects without
+// their key into ErrEncryptionKeyRequired: an InvalidRequest saying the object is
+// encrypted, or for HeadObject, whose errors have no body, a bare 400 (which the SDK
+// reports as BadRequest).
+func mapCustomerKeyError(ctx context.Context, input middleware.InitializeInput,
+	next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	output, metadata, err := next.HandleInitialize(ctx, input)
+	var apiErr smithy.APIError
+	if err == nil || !errors.As(err, &apiErr) {
+		return output, metadata, err
+	}
+	switch awsmiddleware.GetOperationName(ctx) {
+	case "GetObject", "CopyObject", "GetObjectAttributes":
+		if apiErr.ErrorCode() == "InvalidRequest" && strings.HasPrefix(apiErr.ErrorMessage(), sseCustomerKeyMessage) {
+			err = fmt.Errorf("%w: %w", ErrEncryptionKeyRequired, err)
+		}
+	case "HeadObject":
+		var withStatus interface{ HTTPStatusCode() int }
+		if errors.As(err, &withStatus) && withStatus.HTTPStatusCode() == 400 && apiErr.ErrorCode() == "BadRequest" {
+			err = fmt.Errorf("%w: %w", ErrEncryptionKeyRequired, err)
+		}
+	}
+	return output, metadata, err
+}
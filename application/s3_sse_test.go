@@ -0,0 +1,92 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+const sseCustomerKeyError = `<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>InvalidRequest</Code><Message>The object was stored using a form of Server Side Encryption. ` +
+	`The correct parameters must be provided to retrieve the object.</Message></Error>`
+
+func TestCustomerKeyErrors(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		stub stubTransport
+		head bool
+		want bool
+	}{
+		{"GetObject without the key", stubTransport{status: 400, body: sseCustomerKeyError}, false, true},
+		{"other InvalidRequest", stubTransport{status: 400, body: `<Error><Code>InvalidRequest</Code>` +
+			`<Message>Invalid Argument</Message></Error>`}, false, false},
+		{"other 400", stubTransport{status: 400, body: `<Error><Code>InvalidArgument</Code>` +
+			`<Message>Invalid Argument</Message></Error>`}, false, false},
+		{"HeadObject without the key", stubTransport{status: 400}, true, true},
+		{"HeadObject forbidden", stubTransport{status: 403}, true, false},
+	}
+	for _, test := range tests {
+		service := newStubService(test.stub)
+		var err error
+		if test.head {
+			_, err = service.GetObjectInfo(ctx, "bucket", "key")
+		} else {
+			_, err = service.OpenObject(ctx, "bucket", "key")
+		}
+		if err == nil {
+			t.Fatalf("%v: no error", test.name)
+		}
+		if errors.Is(err, ErrEncryptionKeyRequired) != test.want {
+			t.Errorf("%v: errors.Is(%v, ErrEncryptionKeyRequired) = %v, want %v",
+				test.name, err, !test.want, test.want)
+		}
+	}
+}
+
+// headerTransport records the headers of every request and answers 200 with an empty
+// CopyObjectResult, which the other operations ignore.
+type headerTransport struct {
+	headers *[]http.Header
+}
+
+func (transport headerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	*transport.headers = append(*transport.headers, request.Header.Clone())
+	return stubTransport{status: 200, body: "<CopyObjectResult></CopyObjectResult>"}.RoundTrip(request)
+}
+
+func TestCustomerKeyIsPerCall(t *testing.T) {
+	ctx := context.Background()
+	key, err := NewCustomerKey(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var headers []http.Header
+	service := newStubService(headerTransport{headers: &headers})
+	if _, err := service.GetObjectInfoWithOptions(ctx, "bucket", "key", DownloadOptions{CustomerKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.GetObjectInfo(ctx, "bucket", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.CopyObjectWithOptions(ctx, "bucket", "key", "bucket", "copy",
+		CopyOptions{SourceCustomerKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 3 {
+		t.Fatalf("got %v requests, want 3", len(headers))
+	}
+	if got := headers[0].Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5"); got != key.KeyMD5 {
+		t.Errorf("keyed HeadObject sent key MD5 %q, want %q", got, key.KeyMD5)
+	}
+	if got := headers[1].Get("X-Amz-Server-Side-Encryption-Customer-Key"); got != "" {
+		t.Errorf("plain HeadObject sent a customer key")
+	}
+	if got := headers[2].Get("X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key-Md5"); got != key.KeyMD5 {
+		t.Errorf("copy sent source key MD5 %q, want %q", got, key.KeyMD5)
+	}
+	if got := headers[2].Get("X-Amz-Server-Side-Encryption-Customer-Key"); got != "" {
+		t.Errorf("copy to an unencrypted destination sent a customer key")
+	}
+}
@@ -0,0 +1,107 @@
+package application
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// newFakeService returns a service backed by a fresh FakeS3 holding the given bucket.
+func newFakeService(t *testing.T, bucketName string) (*s3Service, *FakeS3) {
+	t.Helper()
+	fake := NewFakeS3()
+	_, err := fake.CreateBucket(context.Background(), &s3.CreateBucketInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	return NewS3ServiceWithClient(fake), fake
+}
+
+func TestUploadOptionsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	service, _ := newFakeService(t, "bucket")
+	fileName := filepath.Join(t.TempDir(), "page.html")
+	if err := os.WriteFile(fileName, []byte("<p>bonjour</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	options := UploadOptions{
+		ContentType:     "text/html",
+		CacheControl:    "max-age=60",
+		ContentLanguage: "fr-CA",
+		Metadata:        map[string]string{"owner": "web"},
+	}
+	if _, err := service.UploadFileWithOptions(ctx, "bucket", "page.html", fileName, options); err != nil {
+		t.Fatalf("UploadFileWithOptions: %v", err)
+	}
+
+	info, err := service.GetObjectInfo(ctx, "bucket", "page.html")
+	if err != nil {
+		t.Fatalf("GetObjectInfo: %v", err)
+	}
+	if info.ContentLanguage != options.ContentLanguage {
+		t.Errorf("ContentLanguage = %q, want %q", info.ContentLanguage, options.ContentLanguage)
+	}
+	if info.ContentType != options.ContentType {
+		t.Errorf("ContentType = %q, want %q", info.ContentType, options.ContentType)
+	}
+	if info.CacheControl != options.CacheControl {
+		t.Errorf("CacheControl = %q, want %q", info.CacheControl, options.CacheControl)
+	}
+	if !maps.Equal(info.Metadata, options.Metadata) {
+		t.Errorf("Metadata = %v, want %v", info.Metadata, options.Metadata)
+	}
+	if info.Size != int64(len("<p>bonjour</p>")) {
+		t.Errorf("Size = %v, want %v", info.Size, len("<p>bonjour</p>"))
+	}
+}
+
+func TestUploadPreservingMetadataKeepsHeaders(t *testing.T) {
+	ctx := context.Background()
+	service, fake := newFakeService(t, "bucket")
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, err := fake.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:             aws.String("bucket"),
+		Key:                aws.String("report.csv"),
+		Body:               strings.NewReader("a,b"),
+		ContentType:        aws.String("text/csv"),
+		ContentDisposition: aws.String(`attachment; filename="report.csv"`),
+		Expires:            aws.Time(expires),
+		StorageClass:       types.StorageClassStandardIa,
+		Metadata:           map[string]string{"owner": "finance"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := service.GetObjectInfo(ctx, "bucket", "report.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(fileName, []byte("a,b\n1,2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.UploadPreservingMetadata(ctx, "bucket", "report.csv", fileName); err != nil {
+		t.Fatalf("UploadPreservingMetadata: %v", err)
+	}
+
+	after, err := service.GetObjectInfo(ctx, "bucket", "report.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size != int64(len("a,b\n1,2")) {
+		t.Errorf("Size = %v, want the new body", after.Size)
+	}
+	if after.ContentType != before.ContentType || after.ContentDisposition != before.ContentDisposition ||
+		!after.Expires.Equal(before.Expires) || after.StorageClass != before.StorageClass ||
+		!maps.Equal(after.Metadata, before.Metadata) {
+		t.Errorf("headers changed from %+v to %+v", before, after)
+	}
+}
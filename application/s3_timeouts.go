@@ -0,0 +1,53 @@
+package application
+
+import (
+	"context"
+	"io"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// operationTimeout returns the timeout configured for the category of an S3 operation.
+func (service *s3Service) operationTimeout(operation string) time.Duration {
+	switch operation {
+	case "ListObjectsV2", "ListBuckets":
+		return service.ListTimeout
+	case "HeadObject", "HeadBucket", "GetObjectAttributes", "GetBucketLocation":
+		return service.MetadataTimeout
+	case "GetObject", "PutObject", "UploadPart", "CopyObject", "CompleteMultipartUpload":
+		return service.TransferTimeout
+	}
+	return 0
+}
+
+// cancelOnClose releases the deadline of a GetObject request when its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelOnClose) Close() error {
+	defer body.cancel()
+	return body.ReadCloser.Close()
+}
+
+// applyTimeout puts the deadline of its category on each request. Downloads keep it
+// until their body is closed, so it also bounds reading the body.
+func (service *s3Service) applyTimeout(ctx context.Context, input middleware.InitializeInput,
+	next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	timeout := service.operationTimeout(awsmiddleware.GetOperationName(ctx))
+	if timeout <= 0 {
+		return next.HandleInitialize(ctx, input)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	output, metadata, err := next.HandleInitialize(ctx, input)
+	if result, ok := output.Result.(*s3.GetObjectOutput); ok && err == nil && result.Body != nil {
+		result.Body = &cancelOnClose{ReadCloser: result.Body, cancel: cancel}
+	} else {
+		cancel()
+	}
+	return output, metadata, err
+}
@@ -0,0 +1,327 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// minPartSize is the smallest part size S3 accepts for all but the last part.
+const minPartSize int64 = 5 * 1024 * 1024
+
+// uploadSizes returns the part size and multipart threshold in effect, rejecting
+// combinations that can't work.
+func (service *s3Service) uploadSizes() (int64, int64, error) {
+	partSize := service.PartSize
+	if partSize == 0 {
+		partSize = defaultPartSize
+	}
+	threshold := service.MultipartThreshold
+	if threshold == 0 {
+		threshold = partSize
+	}
+	if partSize < minPartSize {
+		return 0, 0, fmt.Errorf("part size %v is below the S3 minimum of %v", partSize, minPartSize)
+	}
+	if threshold < partSize {
+		return 0, 0, fmt.Errorf("multipart threshold %v is below the part size %v", threshold, partSize)
+	}
+	return partSize, threshold, nil
+}
+
+// newUploader returns an upload manager sized to the service limits, together with
+// the function that releases the concurrency slots it was granted.
+func (service *s3Service) newUploader(ctx context.Context) (*manager.Uploader, func(), error) {
+	partSize, _, err := service.uploadSizes()
+	if err != nil {
+		return nil, nil, err
+	}
+	uploader := manager.NewUploader(service.uploadClient(), func(u *manager.Uploader) {
+		u.PartSize = partSize
+		u.Concurrency = service.clampConcurrency(ctx, u.Concurrency)
+	})
+	release, err := service.acquire(ctx, uploader.Concurrency)
+	if err != nil {
+		return nil, nil, err
+	}
+	return uploader, release, nil
+}
+
+// upload sends input in a single PutObject request when its body of size bytes is
+// within the multipart threshold, and through the upload manager otherwise. A
+// negative size means unknown and always goes through the manager.
+func (service *s3Service) upload(ctx context.Context, input *s3.PutObjectInput, size int64) (string, error) {
+	_, threshold, err := service.uploadSizes()
+	if err != nil {
+		return "", err
+	}
+	defer service.invalidateInfo(aws.ToString(input.Bucket), aws.ToString(input.Key))
+	if size >= 0 && size <= threshold {
+		input.ContentLength = aws.Int64(size)
+		result, err := service.s3Client.PutObject(ctx, input)
+		if err != nil {
+			return "", err
+		}
+		return aws.ToString(result.VersionId), nil
+	}
+	uploader, release, err := service.newUploader(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	result, err := uploader.Upload(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(result.VersionID), nil
+}
+
+// UploadSeeker uploads size bytes read from body. Bodies up to MultipartThreshold are
+// sent in a single PutObject request with a known ContentLength, which is cheaper than
+// a multipart upload; larger bodies go through the upload manager.
+// It returns the VersionId of the new object, which is empty on unversioned buckets.
+func (service *s3Service) UploadSeeker(ctx context.Context, bucketName string, objectKey string,
+	body io.ReadSeeker, size int64) (string, error) {
+	versionId, err := service.upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+		Body:   body,
+	}, size)
+	if err != nil {
+		log.Printf("Couldn't upload %v bytes to %v:%v. Here's why: %v\n", size, bucketName, objectKey, err)
+	}
+	return versionId, err
+}
+
+// UploadBytes uploads data to an object, in a single request when it is within
+// MultipartThreshold and as a multipart upload otherwise.
+// It returns the VersionId of the new object, which is empty on unversioned buckets.
+func (service *s3Service) UploadBytes(ctx context.Context, bucketName string, objectKey string, data []byte,
+	options UploadOptions) (string, error) {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+		Body:   bytes.NewReader(data),
+	}
+	options.applyTo(input)
+	versionId, err := service.upload(ctx, input, int64(len(data)))
+	if err != nil {
+		log.Printf("Couldn't upload %v bytes to %v:%v. Here's why: %v\n", len(data), bucketName, objectKey, err)
+	}
+	return versionId, err
+}
+
+// UploadReader streams everything read from r into an object, so the body never needs
+// to fit in memory or have a known size. Up to MultipartThreshold bytes are read ahead:
+// bodies that end within them are sent in a single request, longer ones go through the
+// upload manager.
+// It returns the VersionId of the new object, which is empty on unversioned buckets.
+func (service *s3Service) UploadReader(ctx context.Context, bucketName string, objectKey string, r io.Reader,
+	options UploadOptions) (string, error) {
+	_, threshold, err := service.uploadSizes()
+	if err != nil {
+		return "", err
+	}
+	var head bytes.Buffer
+	read, err := io.CopyN(&head, r, threshold+1)
+	if err != nil && err != io.EOF {
+		log.Printf("Couldn't read stream for %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+		return "", err
+	}
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+		Body:   bytes.NewReader(head.Bytes()),
+	}
+	options.applyTo(input)
+	size := read
+	if read > threshold {
+		input.Body = io.MultiReader(&head, r)
+		size = -1
+	}
+	versionId, err := service.upload(ctx, input, size)
+	if err != nil {
+		log.Printf("Couldn't upload stream to %v:%v. Here's why: %v\n", bucketName, objectKey, err)
+	}
+	return versionId, err
+}
+
+// UploadDirectory uploads every file under localDir to bucketName, using each file's
+// path relative to localDir (with forward slashes) as its key.
+func (service *s3Service) UploadDirectory(ctx context.Context, localDir string, bucketName string) (int, error) {
+	return service.UploadDirectorySharded(ctx, localDir, func(string) string { return bucketName })
+}
+
+// UploadDirectorySharded uploads every file under localDir concurrently, sending each
+// one to the bucket bucketFor picks from its relative path. Keys are the relative
+// paths with forward slashes. It returns how many files were uploaded; the error
+// joins the failures of individual files.
+func (service *s3Service) UploadDirectorySharded(ctx context.Context, localDir string,
+	bucketFor func(relPath string) string) (int, error) {
+	var relPaths []string
+	err := filepath.WalkDir(localDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		relPath, err := filepath.Rel(localDir, path)
+		if err != nil {
+			return err
+		}
+		relPaths = append(relPaths, relPath)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Couldn't walk directory %v. Here's why: %v\n", localDir, err)
+		return 0, err
+	}
+
+	var uploaded atomic.Int64
+	err = service.forEach(ctx, 0, len(relPaths), func(ctx context.Context, i int) error {
+		relPath := relPaths[i]
+		if err := service.uploadPath(ctx, bucketFor(relPath), filepath.ToSlash(relPath),
+			filepath.Join(localDir, relPath)); err != nil {
+			return fmt.Errorf("%v: %w", relPath, err)
+		}
+		uploaded.Add(1)
+		return nil
+	})
+	return int(uploaded.Load()), err
+}
+
+// uploadPath uploads a local file through UploadSeeker, so large files go multipart.
+func (service *s3Service) uploadPath(ctx context.Context, bucketName string, objectKey string, fileName string) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		log.Printf("Couldn't open file %v to upload. Here's why: %v\n", fileName, err)
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		log.Printf("Couldn't stat file %v. Here's why: %v\n", fileName, err)
+		return err
+	}
+	_, err = service.UploadSeeker(ctx, bucketName, objectKey, file, stat.Size())
+	return err
+}
+
+// detectContentType guesses the content type of a file from its extension, falling
+// back to sniffing its first bytes.
+func detectContentType(fileName string) (string, error) {
+	if contentType := mime.TypeByExtension(filepath.Ext(fileName)); contentType != "" {
+		return contentType, nil
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	buffer := make([]byte, 512)
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(buffer[:n]), nil
+}
+
+// UploadPreservingMetadata replaces the body of an object with a file while keeping the
+// headers the object already had: the same set rewriteHeaders keeps, i.e. the content
+// headers, Expires, user metadata, storage class and KMS key. PutObject has no metadata
+// directive, so they are read with HeadObject and sent again explicitly. When the object
+// doesn't exist yet, the file is uploaded with a content type detected from it.
+func (service *s3Service) UploadPreservingMetadata(ctx context.Context, bucketName string, objectKey string,
+	fileName string) (string, error) {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}
+	service.invalidateInfo(bucketName, objectKey)
+	info, err := service.GetObjectInfo(ctx, bucketName, objectKey)
+	var notFound *types.NotFound
+	switch {
+	case err == nil:
+		objectHeaders{
+			&input.ContentType, &input.CacheControl, &input.ContentLanguage, &input.ContentEncoding,
+			&input.ContentDisposition, &input.Expires, &input.Metadata, &input.StorageClass,
+			&input.ServerSideEncryption, &input.SSEKMSKeyId,
+		}.setFrom(info)
+	case errors.As(err, &notFound):
+		contentType, err := detectContentType(fileName)
+		if err != nil {
+			log.Printf("Couldn't detect the content type of %v. Here's why: %v\n", fileName, err)
+			return "", err
+		}
+		input.ContentType = aws.String(contentType)
+	default:
+		return "", err
+	}
+	return service.putFile(ctx, input, fileName)
+}
+
+// objectWriter is the write end of the pipe NewObjectWriter uploads from.
+type objectWriter struct {
+	ctx       context.Context
+	pipe      *io.PipeWriter
+	done      chan error
+	closeOnce sync.Once
+	err       error
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	n, err := w.pipe.Write(p)
+	if err == io.ErrClosedPipe && w.ctx.Err() != nil {
+		err = w.ctx.Err()
+	}
+	return n, err
+}
+
+// Close ends the body and waits for the upload to finish, returning its error.
+func (w *objectWriter) Close() error {
+	w.closeOnce.Do(func() {
+		w.pipe.Close()
+		w.err = <-w.done
+	})
+	return w.err
+}
+
+// NewObjectWriter returns a writer whose data is uploaded to an object as it is
+// written, through UploadReader running in the background, so the object can be
+// produced with json.Encoder, io.Copy and the like without buffering it whole. Close
+// must be called to finish the upload and returns its error; writes fail as soon as the
+// upload does. Canceling ctx aborts the upload even if the writer is never closed.
+func (service *s3Service) NewObjectWriter(ctx context.Context, bucketName string, objectKey string,
+	options UploadOptions) (io.WriteCloser, error) {
+	if _, _, err := service.uploadSizes(); err != nil {
+		return nil, err
+	}
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		// Ending the body with the context error unblocks an upload waiting for data.
+		stop := context.AfterFunc(ctx, func() { writer.CloseWithError(ctx.Err()) })
+		defer stop()
+		_, err := service.UploadReader(ctx, bucketName, objectKey, reader, options)
+		if err != nil {
+			reader.CloseWithError(err)
+		} else {
+			reader.Close()
+		}
+		done <- err
+	}()
+	return &objectWriter{ctx: ctx, pipe: writer, done: done}, nil
+}
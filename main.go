@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -41,6 +42,12 @@ func main() {
 		),
 	})
 
+	err = application.S3.VerifyCredentials(context.TODO())
+
+	if err != nil {
+		log.Fatalln("Error verifying credentials >> ", err)
+	}
+
 	objects, err := application.S3.ListObjects(config.Variables.AwsS3Bucket)
 
 	if err != nil {